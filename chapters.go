@@ -0,0 +1,213 @@
+package podownloader
+
+import (
+	"PoDownloader/util"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/vbauerster/mpb/v8"
+	"github.com/vbauerster/mpb/v8/decor"
+	"io"
+	"net/http"
+	"path/filepath"
+)
+
+// JobTypeChapters is the job type of ChapterTask
+const JobTypeChapters = "Chapters"
+
+// ChapterListVersion is the version of the chapters.json schema, which follows the Podcasting 2.0 JSON chapters format
+const ChapterListVersion = "1.2.0"
+
+// maxChaptersSize is the maximum size of a remote chapters JSON file
+const maxChaptersSize = 16 * 1024 * 1024
+
+func init() {
+	RegisterJournalTaskType("chapters", func() Task { return &ChapterTask{} })
+}
+
+// Sources of the chapters in a ChapterList
+const (
+	// ChapterSourcePodcastChapters means the chapters are fetched from the podcast:chapters JSON file
+	ChapterSourcePodcastChapters = "podcast-chapters"
+	// ChapterSourceSimpleChapters means the chapters are the Podlove Simple Chapters (psc:chapters) in the feed
+	ChapterSourceSimpleChapters = "psc"
+	// ChapterSourceShownotes means the chapters are extracted from the timestamp lines in the shownotes
+	ChapterSourceShownotes = "shownotes"
+)
+
+// Chapter is a chapter of an episode, StartTime and EndTime are in seconds
+type Chapter struct {
+	StartTime float64 `json:"startTime"`
+	EndTime   float64 `json:"endTime,omitempty"`
+	Title     string  `json:"title,omitempty"`
+	URL       string  `json:"url,omitempty"`
+	Image     string  `json:"img,omitempty"`
+}
+
+// ChapterList is the normalized chapters of an episode saved as chapters.json, whatever the source of the chapters is
+type ChapterList struct {
+	Version  string     `json:"version"`
+	Source   string     `json:"source"`
+	Chapters []*Chapter `json:"chapters"`
+}
+
+// remoteChapter is a chapter in the podcast:chapters JSON file, the fields that are not normalized are ignored
+type remoteChapter struct {
+	StartTime float64 `json:"startTime"`
+	EndTime   float64 `json:"endTime"`
+	Title     string  `json:"title"`
+	URL       string  `json:"url"`
+	Image     string  `json:"img"`
+	// TOC is false for the chapters that should not be displayed in the table of contents
+	TOC *bool `json:"toc"`
+}
+
+// ParseRemoteChapters parses the content of a podcast:chapters JSON file to a ChapterList,
+// the chapters excluded from the table of contents are skipped
+func ParseRemoteChapters(content []byte) (*ChapterList, error) {
+	var remoteChapterList struct {
+		Chapters []*remoteChapter `json:"chapters"`
+	}
+	err := json.Unmarshal(content, &remoteChapterList)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chapters: %w", err)
+	}
+	chapterList := &ChapterList{
+		Version:  ChapterListVersion,
+		Source:   ChapterSourcePodcastChapters,
+		Chapters: []*Chapter{},
+	}
+	for _, chapter := range remoteChapterList.Chapters {
+		if chapter == nil || (chapter.TOC != nil && !*chapter.TOC) {
+			continue
+		}
+		chapterList.Chapters = append(chapterList.Chapters, &Chapter{
+			StartTime: chapter.StartTime,
+			EndTime:   chapter.EndTime,
+			Title:     chapter.Title,
+			URL:       chapter.URL,
+			Image:     chapter.Image,
+		})
+	}
+	return chapterList, nil
+}
+
+// ChapterTask is a task that saves the chapters of an episode to Dest as a ChapterList,
+// the chapters are fetched from URL if it is not empty, otherwise the inline Chapters are saved
+type ChapterTask struct {
+	JobName string `json:"jobName,omitempty"`
+	JobType string `json:"jobType,omitempty"`
+	// URL is the URL of the podcast:chapters JSON file, empty if the chapters are inline
+	URL string `json:"url,omitempty"`
+	// Chapters are the chapters found in the feed, they are used if URL is empty
+	Chapters *ChapterList `json:"chapters,omitempty"`
+	Dest     string       `json:"dest,omitempty"`
+}
+
+// Execute implements the Task interface, it writes the normalized chapters to ChapterTask.Dest atomically
+// with a progress bar if env.ProgressBar is not nil
+func (t *ChapterTask) Execute(ctx context.Context, env *TaskEnv) error {
+	if env.ProgressBar == nil {
+		return t.save(ctx, env)
+	}
+	taskName := progressBarTaskName(t.JobType, env.Attempt)
+	bar := env.ProgressBar.AddBar(
+		1,
+		mpb.PrependDecorators(
+			decor.Name(taskName, decor.WC{W: len(taskName) + 1, C: decor.DidentRight}),
+			decor.Name(util.GetFirstNCharacters(t.DisplayName(), 20), decor.WCSyncSpaceR),
+		),
+		mpb.AppendDecorators(
+			decor.Percentage(decor.WC{W: 5}),
+		),
+	)
+	err := t.save(ctx, env)
+	if err != nil {
+		bar.Abort(false)
+		return err
+	}
+	bar.IncrBy(1)
+	return nil
+}
+
+// save fetches the chapters if ChapterTask.URL is set and writes the normalized chapters to ChapterTask.Dest atomically
+func (t *ChapterTask) save(ctx context.Context, env *TaskEnv) error {
+	chapterList := t.Chapters
+	if t.URL != "" {
+		var err error
+		chapterList, err = t.fetchChapters(ctx, env)
+		if err != nil {
+			return err
+		}
+	}
+	if chapterList == nil {
+		return fmt.Errorf("no chapters for %s", t.Dest)
+	}
+	content, err := json.MarshalIndent(chapterList, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = util.EnsureDirAll(filepath.Dir(t.Dest))
+	if err != nil {
+		return err
+	}
+	return util.WriteContentToFileAtomically(string(content), t.Dest)
+}
+
+// fetchChapters downloads and parses the podcast:chapters JSON file of ChapterTask.URL
+func (t *ChapterTask) fetchChapters(ctx context.Context, env *TaskEnv) (*ChapterList, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := env.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	env.Stats.SetHTTPStatus(resp.StatusCode)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, newHTTPStatusError(t.URL, resp)
+	}
+	content, err := io.ReadAll(io.LimitReader(&statsReader{reader: resp.Body, stats: env.Stats}, maxChaptersSize))
+	if err != nil {
+		return nil, err
+	}
+	return ParseRemoteChapters(content)
+}
+
+// Destination implements the Task interface
+func (t *ChapterTask) Destination() string {
+	return t.Dest
+}
+
+// Kind implements the Task interface
+func (t *ChapterTask) Kind() string {
+	return t.JobType
+}
+
+// DisplayName implements the Task interface
+func (t *ChapterTask) DisplayName() string {
+	return t.JobName
+}
+
+// SizeHint implements the Task interface, the size of the chapters is unknown
+func (t *ChapterTask) SizeHint() int64 {
+	return 0
+}
+
+// SourceURL implements the RemoteTask interface, returns an empty string if the chapters are inline
+func (t *ChapterTask) SourceURL() string {
+	return t.URL
+}
+
+// OutputFiles implements the OutputTask interface
+func (t *ChapterTask) OutputFiles() []string {
+	return []string{t.Dest}
+}
+
+// IsDestFileExist returns whether the ChapterTask destination file exists,
+// the destination file only exists after the chapters have been completely written
+func (t *ChapterTask) IsDestFileExist() bool {
+	return util.IsFileExist(t.Dest)
+}
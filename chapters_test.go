@@ -0,0 +1,107 @@
+package podownloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/vbauerster/mpb/v8"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testRemoteChapters = `{
+  "version": "1.2.0",
+  "chapters": [
+    {"startTime": 0, "title": "Intro", "img": "http://example.com/intro.jpg"},
+    {"startTime": 60, "title": "Hidden", "toc": false},
+    {"startTime": 90.5, "endTime": 120, "title": "Topic", "url": "http://example.com/topic", "location": {"name": "Austin"}}
+  ]
+}`
+
+func TestParseRemoteChapters(t *testing.T) {
+	chapterList, err := ParseRemoteChapters([]byte(testRemoteChapters))
+	assert.Nil(t, err)
+	assert.Equal(t, &ChapterList{
+		Version: ChapterListVersion,
+		Source:  ChapterSourcePodcastChapters,
+		Chapters: []*Chapter{
+			{StartTime: 0, Title: "Intro", Image: "http://example.com/intro.jpg"},
+			{StartTime: 90.5, EndTime: 120, Title: "Topic", URL: "http://example.com/topic"},
+		},
+	}, chapterList)
+	_, err = ParseRemoteChapters([]byte("<html></html>"))
+	assert.NotNil(t, err)
+}
+
+func TestChapterTask_Execute(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/chapters.json" {
+			http.NotFound(writer, request)
+			return
+		}
+		_, _ = writer.Write([]byte(testRemoteChapters))
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	env := &TaskEnv{HTTPClient: &http.Client{}, Options: DefaultDownloadOptions(), Stats: &TaskStats{}, Attempt: 1}
+
+	remoteTask := &ChapterTask{URL: server.URL + "/chapters.json", Dest: filepath.Join(dir, "1", "chapters.json")}
+	assert.False(t, remoteTask.IsDownloaded())
+	assert.Nil(t, remoteTask.Execute(context.Background(), env))
+	assert.True(t, remoteTask.IsDownloaded())
+	content, err := os.ReadFile(remoteTask.Dest)
+	assert.Nil(t, err)
+	chapterList := &ChapterList{}
+	assert.Nil(t, json.Unmarshal(content, chapterList))
+	assert.Equal(t, ChapterSourcePodcastChapters, chapterList.Source)
+	assert.Equal(t, 2, len(chapterList.Chapters))
+	assert.Equal(t, int64(len(testRemoteChapters)), env.Stats.Bytes())
+
+	inlineTask := &ChapterTask{
+		Chapters: &ChapterList{
+			Version:  ChapterListVersion,
+			Source:   ChapterSourceSimpleChapters,
+			Chapters: []*Chapter{{StartTime: 0, Title: "Intro"}},
+		},
+		Dest: filepath.Join(dir, "2", "chapters.json"),
+	}
+	assert.Nil(t, inlineTask.Execute(context.Background(), env))
+	content, err = os.ReadFile(inlineTask.Dest)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"version": "1.2.0", "source": "psc", "chapters": [{"startTime": 0, "title": "Intro"}]}`, string(content))
+
+	missingTask := &ChapterTask{URL: server.URL + "/missing.json", Dest: filepath.Join(dir, "3", "chapters.json")}
+	err = missingTask.Execute(context.Background(), env)
+	assert.NotNil(t, err)
+	assert.False(t, missingTask.IsDownloaded())
+}
+
+func TestChapterTask_ExecuteWithProgress(t *testing.T) {
+	dir := t.TempDir()
+	var output bytes.Buffer
+	progressBar := mpb.New(mpb.WithOutput(&output), mpb.WithWidth(64))
+	env := &TaskEnv{HTTPClient: &http.Client{}, ProgressBar: progressBar, Options: DefaultDownloadOptions(), Stats: &TaskStats{}, Attempt: 1}
+	inlineTask := &ChapterTask{
+		JobType:  JobTypeChapters,
+		JobName:  "Episode 1",
+		Chapters: &ChapterList{Version: ChapterListVersion, Chapters: []*Chapter{{StartTime: 0, Title: "Intro"}}},
+		Dest:     filepath.Join(dir, "1", "chapters.json"),
+	}
+	assert.Nil(t, inlineTask.Execute(context.Background(), env))
+	missingTask := &ChapterTask{JobType: JobTypeChapters, Dest: filepath.Join(dir, "2", "chapters.json")}
+	assert.NotNil(t, missingTask.Execute(context.Background(), env))
+	// Wait returns only after the bars of both tasks have completed or aborted
+	progressBar.Wait()
+	assert.Contains(t, output.String(), "Episode 1")
+	assert.True(t, inlineTask.IsDownloaded())
+}
+
+func TestChapterTask_JournalTaskType(t *testing.T) {
+	name, err := getJournalTaskTypeName(&ChapterTask{})
+	assert.Nil(t, err)
+	assert.Equal(t, "chapters", name)
+}
@@ -6,6 +6,9 @@ import (
 	"PoDownloader/opml"
 	"PoDownloader/podcast"
 	"PoDownloader/util"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
@@ -13,6 +16,9 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 var (
@@ -20,23 +26,73 @@ var (
 	httpClient    *http.Client
 	podcastParser *podcast.Parser
 	logger        *logger2.Logger
+	// plannedFiles are the output files planned for each podcast feed URL before the downloaded tasks are removed
+	plannedFiles = make(map[string][]string)
 
 	// arguments used in download command
-	rssListFilePath string
-	opmlFilePath    string
-	rss             string
-	outputFolder    string
-	userAgent       string
-	configFilePath  string
-	logFolder       string
-	threadCount     int
+	rssListFilePath   string
+	opmlFilePath      string
+	rss               string
+	outputFolder      string
+	userAgent         string
+	configFilePath    string
+	logFolder         string
+	threadCount       int
+	checkContentType  bool
+	retryAttempts     int
+	retryBackoff      time.Duration
+	retryMaxBackoff   time.Duration
+	retryJitter       float64
+	verifyMode        string
+	downloadOrder     string
+	hostThreadCount   int
+	hostDelay         time.Duration
+	limitRate         string
+	limitRatePerTask  string
+	resume            bool
+	segmentCount      int
+	segmentThreshold  string
+	reportFilePath    string
+	dryRun            bool
+	dryRunFormat      string
+	minFreeSpace      string
+	spaceCheck        string
+	recordManifest    bool
+	dedupeContent     bool
+	useFeedCache      bool
+	feedPages         int
+	progressMode      string
+	eventsFormat      string
+	transcripts       string
+	shownotesChapters bool
 
 	downloadCmd = &cobra.Command{
 		Use:   "download",
 		Short: "Download podcasts",
 		Long: `Download podcasts
 
-Receiving SIGINT or SIGTERM while downloading will wait until all download tasks have been downloaded before exiting the program.
+Receiving SIGINT or SIGTERM while downloading will stop starting new download tasks and wait until the in-flight download tasks have been downloaded before exiting the program.
+Receiving the signal again will abort the in-flight download tasks, partial files are kept and will be resumed on the next run.
+
+Run with --dry-run to print the download plan with estimated sizes without downloading anything.
+
+The download plan and the state of every download task are recorded to a journal file in the output folder,
+run with --resume to continue a crashed or killed run from the journal without parsing the podcasts again.
+
+Before downloading, the estimated download size is compared with the free disk space of the output folder,
+and no new download tasks are started when the free disk space drops below --min-free-space.
+
+The SHA-256 checksum of every downloaded file is recorded to the checksum manifest of its podcast folder,
+run the verify command to detect missing, modified or truncated files.
+
+The HTTP validators and parse results of the feeds are cached in the output folder, a podcast is skipped
+if its feed is not modified and there was nothing to download in it last time.
+
+Progress bars are displayed when stdout is a terminal, otherwise a plain line is logged for each parsed feed,
+started and finished download task. Run with --events jsonl to write structured events to stdout as JSON lines instead.
+
+A URL shared by several destinations, such as a cover reused by every episode or an episode republished in several podcasts,
+is downloaded only once and hard linked (or copied) to the other destinations.
 `,
 		Run: download,
 	}
@@ -54,6 +110,33 @@ func init() {
 	downloadCmd.Flags().StringVarP(&configFilePath, "config", "c", "", "Configuration file (default is $PWD/.podownloader)")
 	downloadCmd.Flags().StringVar(&logFolder, "log", "", "Log folder path, if you leave this blank, no logs will be generated")
 	downloadCmd.Flags().IntVarP(&threadCount, "thread", "t", 3, "Download threads")
+	downloadCmd.Flags().IntVar(&hostThreadCount, "host-threads", 0, "Maximum concurrent downloads per host, 0 means no limit")
+	downloadCmd.Flags().DurationVar(&hostDelay, "host-delay", 0, "Minimum delay between two downloads started on the same host")
+	downloadCmd.Flags().StringVar(&limitRate, "limit-rate", "", "Maximum aggregate download speed of all download threads in bytes per second, e.g. 512K or 2M, empty means no limit")
+	downloadCmd.Flags().StringVar(&limitRatePerTask, "limit-rate-per-download", "", "Maximum download speed of each download in bytes per second, e.g. 512K or 2M, empty means no limit")
+	downloadCmd.Flags().IntVar(&segmentCount, "segments", 0, "Maximum connections used to download a large enclosure in segments, the connections count toward the download threads, 0 or 1 disables segmented downloading")
+	downloadCmd.Flags().StringVar(&segmentThreshold, "segment-threshold", "100M", "Minimum size of an enclosure downloaded in segments, e.g. 50M or 1G")
+	downloadCmd.Flags().StringVar(&minFreeSpace, "min-free-space", "", "Free disk space to keep in the output folder, e.g. 500M or 2G, downloads are paused when the free disk space drops below it, empty means no reserve")
+	downloadCmd.Flags().StringVar(&spaceCheck, "space-check", "warn", "What to do when the estimated download size exceeds the free disk space before downloading: warn, refuse or off")
+	downloadCmd.Flags().StringVar(&downloadOrder, "order", "feed", "Download order: feed (podcast by podcast), newest (newest episodes first), oldest (oldest episodes first), round-robin (take turns between podcasts, newest episodes first) or metadata-first (RSS, covers, shownotes, transcripts and chapters before enclosures)")
+	downloadCmd.Flags().StringVar(&transcripts, "transcripts", "srt,vtt,json,html,txt", "Comma separated formats of the episode transcripts to download: srt, vtt, json, html and txt, all formats by default, empty means no transcripts")
+	downloadCmd.Flags().BoolVar(&shownotesChapters, "shownotes-chapters", false, "Extract the episode chapters from the timestamp lines in the shownotes when the feed declares no chapters")
+	downloadCmd.Flags().StringVar(&verifyMode, "verify", "exist", "How to check whether a file is already downloaded: exist (file exists), size (file size matches the length declared in the feed) or strict (file size matches the Content-Length of a HEAD request)")
+	downloadCmd.Flags().IntVar(&retryAttempts, "retry", 3, "Maximum attempts of each download task, including the first attempt")
+	downloadCmd.Flags().DurationVar(&retryBackoff, "retry-backoff", time.Second, "Delay before the first retry, the delay doubles after each retry")
+	downloadCmd.Flags().DurationVar(&retryMaxBackoff, "retry-max-backoff", 30*time.Second, "Maximum delay between two attempts, including the delay requested by Retry-After")
+	downloadCmd.Flags().Float64Var(&retryJitter, "retry-jitter", 0.2, "Fraction of the retry delay that is randomized, between 0 and 1")
+	downloadCmd.Flags().BoolVar(&resume, "resume", false, "Continue the last run from the journal in the output folder without parsing the podcasts again")
+	downloadCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the download plan with estimated sizes instead of downloading")
+	downloadCmd.Flags().StringVar(&dryRunFormat, "dry-run-format", "table", "Output format of the download plan: table or json")
+	downloadCmd.Flags().BoolVar(&useFeedCache, "feed-cache", true, "Fetch the feeds conditionally with the cached ETag and Last-Modified, and skip the podcasts whose feeds are not modified and have nothing to download")
+	downloadCmd.Flags().IntVar(&feedPages, "feed-pages", 1, "Maximum number of documents fetched for each feed by following its RFC 5005 next and prev-archive links, 1 fetches the first document only")
+	downloadCmd.Flags().BoolVar(&recordManifest, "manifest", true, "Record the SHA-256 checksums of downloaded files to the checksum manifest of each podcast folder")
+	downloadCmd.Flags().BoolVar(&dedupeContent, "dedupe-content", false, "Replace downloaded files with hard links to the files already on disk with the same SHA-256 checksum recorded in the checksum manifests")
+	downloadCmd.Flags().StringVar(&progressMode, "progress", "auto", "How to display the progress: auto (bar if stdout is a terminal, otherwise plain), bar (progress bars) or plain (a line for each parsed feed, started and finished download)")
+	downloadCmd.Flags().StringVar(&eventsFormat, "events", "", "Write the events of parsing feeds and downloading to stdout, available formats: jsonl (a JSON object per line), empty means no events")
+	downloadCmd.Flags().StringVar(&reportFilePath, "report", "", "Write a report of every feed and download task to the file, CSV if the file name ends with .csv, otherwise JSON")
+	downloadCmd.Flags().BoolVar(&checkContentType, "check-content-type", false, "Fail downloads whose response Content-Type does not match the file type (audio/video, image or xml)")
 
 	// Define configuration keys
 	_ = viper.BindPFlag("list", rootCmd.Flags().Lookup("list"))
@@ -63,11 +146,51 @@ func init() {
 	_ = viper.BindPFlag("ua", rootCmd.Flags().Lookup("ua"))
 	_ = viper.BindPFlag("thread", rootCmd.Flags().Lookup("thread"))
 	_ = viper.BindPFlag("log", rootCmd.Flags().Lookup("log"))
+	_ = viper.BindPFlag("check-content-type", rootCmd.Flags().Lookup("check-content-type"))
+	_ = viper.BindPFlag("feed-cache", rootCmd.Flags().Lookup("feed-cache"))
+	_ = viper.BindPFlag("feed-pages", rootCmd.Flags().Lookup("feed-pages"))
+	_ = viper.BindPFlag("manifest", rootCmd.Flags().Lookup("manifest"))
+	_ = viper.BindPFlag("dedupe-content", rootCmd.Flags().Lookup("dedupe-content"))
+	_ = viper.BindPFlag("progress", rootCmd.Flags().Lookup("progress"))
+	_ = viper.BindPFlag("events", rootCmd.Flags().Lookup("events"))
+	_ = viper.BindPFlag("report", rootCmd.Flags().Lookup("report"))
+	_ = viper.BindPFlag("host-threads", rootCmd.Flags().Lookup("host-threads"))
+	_ = viper.BindPFlag("host-delay", rootCmd.Flags().Lookup("host-delay"))
+	_ = viper.BindPFlag("limit-rate", rootCmd.Flags().Lookup("limit-rate"))
+	_ = viper.BindPFlag("limit-rate-per-download", rootCmd.Flags().Lookup("limit-rate-per-download"))
+	_ = viper.BindPFlag("segments", rootCmd.Flags().Lookup("segments"))
+	_ = viper.BindPFlag("segment-threshold", rootCmd.Flags().Lookup("segment-threshold"))
+	_ = viper.BindPFlag("min-free-space", rootCmd.Flags().Lookup("min-free-space"))
+	_ = viper.BindPFlag("space-check", rootCmd.Flags().Lookup("space-check"))
+	_ = viper.BindPFlag("order", rootCmd.Flags().Lookup("order"))
+	_ = viper.BindPFlag("transcripts", rootCmd.Flags().Lookup("transcripts"))
+	_ = viper.BindPFlag("shownotes-chapters", rootCmd.Flags().Lookup("shownotes-chapters"))
+	_ = viper.BindPFlag("verify", rootCmd.Flags().Lookup("verify"))
+	_ = viper.BindPFlag("retry", rootCmd.Flags().Lookup("retry"))
+	_ = viper.BindPFlag("retry-backoff", rootCmd.Flags().Lookup("retry-backoff"))
+	_ = viper.BindPFlag("retry-max-backoff", rootCmd.Flags().Lookup("retry-max-backoff"))
+	_ = viper.BindPFlag("retry-jitter", rootCmd.Flags().Lookup("retry-jitter"))
 
 	// Set default configuration value
 	viper.SetDefault("output", "podcast")
 	viper.SetDefault("ua", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.77 Safari/537.36")
 	viper.SetDefault("thread", 3)
+	viper.SetDefault("feed-cache", true)
+	viper.SetDefault("feed-pages", 1)
+	viper.SetDefault("manifest", true)
+	viper.SetDefault("progress", "auto")
+	viper.SetDefault("host-threads", 0)
+	viper.SetDefault("host-delay", 0)
+	viper.SetDefault("segments", 0)
+	viper.SetDefault("segment-threshold", "100M")
+	viper.SetDefault("space-check", "warn")
+	viper.SetDefault("order", "feed")
+	viper.SetDefault("transcripts", "srt,vtt,json,html,txt")
+	viper.SetDefault("verify", "exist")
+	viper.SetDefault("retry", 3)
+	viper.SetDefault("retry-backoff", time.Second)
+	viper.SetDefault("retry-max-backoff", 30*time.Second)
+	viper.SetDefault("retry-jitter", 0.2)
 
 	httpClient = util.NewHTTPClient(userAgent)
 	podcastParser = podcast.NewPodcastParser(httpClient, userAgent)
@@ -101,19 +224,55 @@ func getPodcastRSSList() ([]string, error) {
 	return nil, errors.New("")
 }
 
-func download(cmd *cobra.Command, _ []string) {
-	// Close log file after download task completed
-	defer func() {
-		if logger != nil {
-			logger.CloseFile()
+// getDownloadOptions returns the download options built from the command line arguments
+func getDownloadOptions() (*podownloader.DownloadOptions, error) {
+	downloadOptions := podownloader.DefaultDownloadOptions()
+	downloadOptions.CheckContentType = checkContentType
+	downloadOptions.MaxTasksPerHost = hostThreadCount
+	downloadOptions.HostInterval = hostDelay
+	downloadOptions.RetryPolicy = &podownloader.RetryPolicy{
+		MaxAttempts: retryAttempts,
+		BaseBackoff: retryBackoff,
+		MaxBackoff:  retryMaxBackoff,
+		Jitter:      retryJitter,
+	}
+	if limitRate != "" {
+		bytesPerSecond, err := util.ParseByteSize(limitRate)
+		if err != nil {
+			return nil, err
 		}
-	}()
-	if opmlFilePath == "" && rssListFilePath == "" && rss == "" {
-		fmt.Println("Please specify at least one argument among \"opml\", \"list\" and \"rss\"")
-		fmt.Println()
-		_ = cmd.Help()
-		os.Exit(1)
+		if bytesPerSecond > 0 {
+			downloadOptions.RateLimiter = util.NewRateLimiter(bytesPerSecond)
+		}
+	}
+	if limitRatePerTask != "" {
+		bytesPerSecond, err := util.ParseByteSize(limitRatePerTask)
+		if err != nil {
+			return nil, err
+		}
+		downloadOptions.PerDownloadRateLimit = bytesPerSecond
+	}
+	downloadOptions.SegmentCount = segmentCount
+	if segmentThreshold != "" {
+		threshold, err := util.ParseByteSize(segmentThreshold)
+		if err != nil {
+			return nil, err
+		}
+		downloadOptions.SegmentThreshold = threshold
+	}
+	if minFreeSpace != "" {
+		reserve, err := util.ParseByteSize(minFreeSpace)
+		if err != nil {
+			return nil, err
+		}
+		downloadOptions.MinFreeSpace = reserve
 	}
+	return downloadOptions, nil
+}
+
+// planDownloadTasks parses the podcasts, removes the downloaded tasks and returns the download tasks
+// with the parse results of the feeds, the returned download tasks are empty when there is nothing to download
+func planDownloadTasks(parsedVerifyMode podownloader.VerifyMode, parsedProgressMode podownloader.ProgressMode, taskOptions *podcast.TaskOptions, planHash string) ([]*podownloader.PodcastDownloadTask, []*podownloader.FeedResult) {
 	podcastRSSList, err := getPodcastRSSList()
 	if err != nil {
 		log.Fatalln("Can not load RSS list:", err)
@@ -129,7 +288,13 @@ func download(cmd *cobra.Command, _ []string) {
 		}
 	}
 
-	podcastList, failed := podcastParser.ParsePodcastsFromRSSListWithProgress(podcastRSSList)
+	podcastList, feedResults := podcastParser.ParsePodcastsFromRSSListWithProgress(podcastRSSList, threadCount)
+	var failed []*podownloader.FeedResult
+	for _, feedResult := range feedResults {
+		if feedResult.Err != nil {
+			failed = append(failed, feedResult)
+		}
+	}
 
 	if len(podcastList) != 0 {
 		logger.Println(fmt.Sprintf("Successfully parsed %d RSS link(s)", len(podcastList)))
@@ -138,43 +303,302 @@ func download(cmd *cobra.Command, _ []string) {
 	// Print parse failed podcast RSS links
 	if len(failed) != 0 {
 		logger.Println(fmt.Sprintf("%d RSS link(s) parsing failed:", len(failed)))
-		for index, rss := range failed {
-			logger.Println(fmt.Sprintf("%d. %s", index+1, rss))
+		for index, feedResult := range failed {
+			logger.Println(fmt.Sprintf("%d. %s (%s): %s", index+1, feedResult.URL, feedResult.ErrorClass(), feedResult.Err))
 		}
 	}
 
 	// Exit when there are no podcasts to download
 	if len(podcastList) == 0 {
 		logger.Println("No RSS links to download, exit")
-		os.Exit(0)
+		return nil, feedResults
 	}
 
-	var podcastDownloadTasks []*podownloader.PodcastDownloadTask
+	var (
+		podcastDownloadTasks []*podownloader.PodcastDownloadTask
+		skippedCount         int
+	)
 	for _, p := range podcastList {
-		tasks := p.GetPodcastDownloadTask(outputFolder, httpClient, logger)
+		if p.NotModified && podcastParser.Cache != nil && podcastParser.Cache.IsComplete(p.RSS, planHash) {
+			skippedCount++
+			continue
+		}
+		tasks := p.GetPodcastDownloadTask(outputFolder, httpClient, logger, taskOptions)
+		plannedFiles[p.RSS] = tasks.OutputFiles()
 		podcastDownloadTasks = append(podcastDownloadTasks, tasks)
 	}
+	if skippedCount > 0 {
+		logger.Println(fmt.Sprintf("%d podcast(s) not modified since the last run, skipped", skippedCount))
+	}
 	podcastDownloadTaskIterator := podownloader.NewDownloadTaskIterator(podcastDownloadTasks)
-	podcastDownloadTaskIterator.RemoveDownloadedTask(threadCount)
+	podcastDownloadTaskIterator.ProgressMode = parsedProgressMode
+	podcastDownloadTaskIterator.RemoveDownloadedTask(threadCount, httpClient, parsedVerifyMode)
 
 	if len(podcastDownloadTaskIterator.PodcastDownloadTasks) == 0 {
 		logger.Println("No download tasks, exit")
-		os.Exit(0)
+		return nil, feedResults
+	}
+
+	return podcastDownloadTaskIterator.PodcastDownloadTasks, feedResults
+}
+
+func download(cmd *cobra.Command, _ []string) {
+	// Exit with exitCode after the deferred functions below are done
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+	// Close log file after download task completed
+	defer func() {
+		if logger != nil {
+			logger.CloseFile()
+		}
+	}()
+	if opmlFilePath == "" && rssListFilePath == "" && rss == "" && !resume {
+		fmt.Println("Please specify at least one argument among \"opml\", \"list\" and \"rss\", or resume the last run with \"resume\"")
+		fmt.Println()
+		_ = cmd.Help()
+		os.Exit(1)
+	}
+	parsedVerifyMode, err := podownloader.ParseVerifyMode(verifyMode)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	parsedDownloadOrder, err := podownloader.ParseDownloadOrder(downloadOrder)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	downloadOptions, err := getDownloadOptions()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if dryRun && resume {
+		log.Fatalln("\"dry-run\" can not be used with \"resume\"")
+	}
+	if dryRunFormat != "table" && dryRunFormat != "json" {
+		log.Fatalln(fmt.Sprintf("unknown dry run format: %s, available formats: table, json", dryRunFormat))
+	}
+	if spaceCheck != "warn" && spaceCheck != "refuse" && spaceCheck != "off" {
+		log.Fatalln(fmt.Sprintf("unknown space check mode: %s, available modes: warn, refuse, off", spaceCheck))
+	}
+	taskOptions := podcast.DefaultTaskOptions()
+	taskOptions.TranscriptFormats, err = podcast.ParseTranscriptFormats(transcripts)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	taskOptions.ShownotesChapters = shownotesChapters
+	planHash := getPlanHash(taskOptions, parsedVerifyMode)
+	parsedProgressMode, err := podownloader.ParseProgressMode(progressMode)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if eventsFormat != "" && eventsFormat != "jsonl" {
+		log.Fatalln(fmt.Sprintf("unknown events format: %s, available formats: jsonl", eventsFormat))
+	}
+	if eventsFormat != "" {
+		if progressMode == string(podownloader.ProgressModeBar) {
+			log.Fatalln("\"events\" can not be used with the bar progress mode, both are written to stdout")
+		}
+		parsedProgressMode = podownloader.ProgressModePlain
+	}
+	downloadOptions.ProgressMode = parsedProgressMode
+	downloadOptions.Events = getEventSink(parsedProgressMode)
+	podcastParser.ProgressMode = parsedProgressMode
+	podcastParser.Events = downloadOptions.Events
+	podcastParser.MaxPages = feedPages
+	report := &podownloader.RunReport{StartTime: time.Now()}
+	if reportFilePath != "" {
+		defer saveReport(report)
+	}
+
+	journalPath := filepath.Join(outputFolder, podownloader.JournalFileName)
+	var (
+		downloadQueue        *podownloader.DownloadQueue
+		journal              *podownloader.Journal
+		podcastDownloadTasks []*podownloader.PodcastDownloadTask
+	)
+	if resume {
+		journal, err = podownloader.LoadJournal(journalPath)
+		if err != nil {
+			log.Fatalln("Can not load download journal:", err)
+		}
+		downloadQueue = podownloader.NewDownloadQueueFromJournal(journal)
+		logger.Println(fmt.Sprintf("Resume from download journal: %s", journalPath))
+		logger.Println(fmt.Sprintf("%d download task(s) done, %d download task(s) failed previously", journal.CountByState(podownloader.TaskStateDone), journal.CountByState(podownloader.TaskStateFailed)))
+		if downloadQueue.IsEmpty() {
+			logger.Println("No download tasks, exit")
+			return
+		}
+	} else {
+		if useFeedCache {
+			podcastParser.Cache = podcast.NewFeedCache(filepath.Join(outputFolder, podcast.FeedCacheDirName))
+		}
+		podcastDownloadTasks, report.Feeds = planDownloadTasks(parsedVerifyMode, parsedProgressMode, taskOptions, planHash)
+		if len(podcastDownloadTasks) == 0 {
+			return
+		}
+		if dryRun {
+			printDownloadPlan(podcastDownloadTasks)
+			return
+		}
+		if spaceCheck != "off" && !checkFreeDiskSpace(podcastDownloadTasks, downloadOptions.MinFreeSpace, parsedVerifyMode == podownloader.VerifyModeStrict) && spaceCheck == "refuse" {
+			logger.Println("Not enough free disk space, exit")
+			exitCode = 1
+			return
+		}
+		downloadQueue = podownloader.NewDownloadQueueFromDownloadTasks(podcastDownloadTasks, parsedDownloadOrder)
+		journal, err = downloadQueue.CreateJournal(journalPath)
+		if err != nil {
+			logger.Println(fmt.Sprintf("Can not create download journal, the run can not be resumed: %s", err))
+		}
+	}
+	if journal != nil {
+		defer journal.Close()
+	}
+	if recordManifest {
+		downloadOptions.Manifest = podownloader.NewManifestWriter(outputFolder)
+		defer downloadOptions.Manifest.Close()
+	}
+	if dedupeContent {
+		downloadOptions.ContentIndex, err = podownloader.LoadContentIndex(outputFolder)
+		if err != nil {
+			logger.Println(fmt.Sprintf("Can not load checksum manifests, content deduplication is disabled: %s", err))
+		}
 	}
 
-	downloadQueue := podownloader.NewDownloadQueueFromDownloadTasks(podcastDownloadTaskIterator.PodcastDownloadTasks)
 	logger.Println(fmt.Sprintf("Totally %d download tasks", downloadQueue.Length()))
 	logger.Println("Start download")
-	failedTaskDestPaths := downloadQueue.StartDownload(threadCount, httpClient, logger)
+	downloadResult := downloadQueue.StartDownload(threadCount, httpClient, logger, downloadOptions)
+	report.Tasks = downloadResult.TaskResults
 	logger.Println("Download finished")
+	markCompletePodcasts(podcastDownloadTasks, downloadResult, planHash)
 
 	// Print failed download tasks
-	if len(failedTaskDestPaths) > 0 {
-		logger.Println(fmt.Sprintf("%d file(s) download failed:", len(failedTaskDestPaths)))
-		for index, failedTaskDestPath := range failedTaskDestPaths {
-			logger.Println(fmt.Sprintf("%d. %s", index+1, failedTaskDestPath))
+	if len(downloadResult.FailedTasks) > 0 {
+		logger.Println(fmt.Sprintf("%d file(s) download failed:", len(downloadResult.FailedTasks)))
+		for index, failedTask := range downloadResult.FailedTasks {
+			logger.Println(fmt.Sprintf("%d. %s: %s", index+1, failedTask.Dest, failedTask.Err))
+		}
+	}
+
+	// Print interrupted download tasks
+	if downloadResult.IsInterrupted() {
+		logger.Println("Download interrupted")
+		if len(downloadResult.InterruptedTasks) > 0 {
+			logger.Println(fmt.Sprintf("%d in-flight file(s) aborted, partial files are kept and will be resumed on the next run:", len(downloadResult.InterruptedTasks)))
+			for index, interruptedTask := range downloadResult.InterruptedTasks {
+				logger.Println(fmt.Sprintf("%d. %s", index+1, interruptedTask.Dest))
+			}
+		}
+		logger.Println(fmt.Sprintf("%d file(s) not started", len(downloadResult.UnstartedTasks)))
+		if downloadResult.LowDiskSpace {
+			logger.Println("Download paused because the disk is running out of space, free some space and run with --resume to continue")
+		}
+	}
+}
+
+// getEventSink returns the event sink of the events format, the events are printed as plain lines
+// in the plain progress mode if no events format is specified, returns nil if the events are not emitted
+func getEventSink(parsedProgressMode podownloader.ProgressMode) podownloader.EventSink {
+	if eventsFormat == "jsonl" {
+		return podownloader.NewJSONLinesEventSink(os.Stdout)
+	}
+	if parsedProgressMode == podownloader.ProgressModePlain {
+		return podownloader.NewLogEventSink(logger)
+	}
+	return nil
+}
+
+// getPlanHash returns the hash of the options that determine the planned tasks of a podcast,
+// a podcast marked complete in the feed cache is planned again when the hash changes
+func getPlanHash(taskOptions *podcast.TaskOptions, parsedVerifyMode podownloader.VerifyMode) string {
+	content, _ := json.Marshal(struct {
+		TaskOptions *podcast.TaskOptions
+		VerifyMode  podownloader.VerifyMode
+		FeedPages   int
+	}{taskOptions, parsedVerifyMode, feedPages})
+	digest := sha256.Sum256(content)
+	return hex.EncodeToString(digest[:])
+}
+
+// markCompletePodcasts marks the podcasts that had nothing to download except the feed as complete in the feed cache
+// if all their tasks are done, so that they are skipped while their feeds are not modified, the options do not change
+// and their files exist
+func markCompletePodcasts(podcastDownloadTasks []*podownloader.PodcastDownloadTask, downloadResult *podownloader.DownloadResult, planHash string) {
+	if podcastParser.Cache == nil {
+		return
+	}
+	for _, podcastDownloadTask := range podcastDownloadTasks {
+		if !podcastDownloadTask.IsFeedOnly() {
+			continue
+		}
+		complete := true
+		for _, taskResult := range downloadResult.TaskResults {
+			if taskResult.Status != podownloader.TaskResultDone && strings.HasPrefix(taskResult.Dest, podcastDownloadTask.BaseDestDir+string(filepath.Separator)) {
+				complete = false
+				break
+			}
+		}
+		if !complete {
+			continue
 		}
+		err := podcastParser.Cache.MarkComplete(podcastDownloadTask.RSS, planHash, plannedFiles[podcastDownloadTask.RSS])
+		if err != nil {
+			logger.PrintlnToFile(fmt.Sprintf("Failed to update the feed cache of %s: %s", podcastDownloadTask.RSS, err))
+		}
+	}
+}
+
+// checkFreeDiskSpace compares the estimated size of specified download tasks and the reserve with the free disk space
+// of the output folder, returns false and prints a warning if the free disk space is not enough.
+// The sizes not declared in the feeds are requested by HTTP HEAD requests only if headRequest is true
+func checkFreeDiskSpace(podcastDownloadTasks []*podownloader.PodcastDownloadTask, reserve int64, headRequest bool) bool {
+	freeSpace, err := util.GetFreeDiskSpace(outputFolder)
+	if err != nil {
+		logger.Println(fmt.Sprintf("Can not get the free disk space of %s, skip checking: %s", outputFolder, err))
+		return true
+	}
+	downloadPlan := podownloader.NewDownloadPlan(podcastDownloadTasks, httpClient, threadCount, headRequest)
+	if downloadPlan.TotalSize+reserve <= freeSpace {
+		return true
+	}
+	message := fmt.Sprintf("Estimated download size %s exceeds the free disk space %s of %s", util.FormatByteSize(downloadPlan.TotalSize), util.FormatByteSize(freeSpace), outputFolder)
+	if reserve > 0 {
+		message += fmt.Sprintf(" with %s reserved", util.FormatByteSize(reserve))
+	}
+	if downloadPlan.UnknownSizeCount > 0 {
+		message += fmt.Sprintf(", %d file(s) of unknown size are not counted", downloadPlan.UnknownSizeCount)
+	}
+	logger.Println(message)
+	return false
+}
+
+// printDownloadPlan prints the plan of specified download tasks to stdout in the dry run format
+func printDownloadPlan(podcastDownloadTasks []*podownloader.PodcastDownloadTask) {
+	logger.Println("Estimating download sizes")
+	downloadPlan := podownloader.NewDownloadPlan(podcastDownloadTasks, httpClient, threadCount, true)
+	var err error
+	if dryRunFormat == "json" {
+		err = downloadPlan.WriteJSON(os.Stdout)
+	} else {
+		err = downloadPlan.WriteTable(os.Stdout)
+	}
+	if err != nil {
+		log.Fatalln("Failed to print download plan:", err)
+	}
+}
+
+// saveReport writes the run report to the report file
+func saveReport(report *podownloader.RunReport) {
+	report.EndTime = time.Now()
+	err := report.Save(reportFilePath, podownloader.GetReportFormatByPath(reportFilePath))
+	if err != nil {
+		logger.Println(fmt.Sprintf("Failed to write report to %s: %s", reportFilePath, err))
+		return
 	}
+	logger.Println(fmt.Sprintf("Report has been written to %s", reportFilePath))
 }
 
 // initConfig initialize configuration items
@@ -207,6 +631,30 @@ func initConfig() {
 	userAgent = viper.GetString("ua")
 	threadCount = viper.GetInt("thread")
 	logFolder = viper.GetString("log")
+	checkContentType = viper.GetBool("check-content-type")
+	useFeedCache = viper.GetBool("feed-cache")
+	feedPages = viper.GetInt("feed-pages")
+	recordManifest = viper.GetBool("manifest")
+	dedupeContent = viper.GetBool("dedupe-content")
+	progressMode = viper.GetString("progress")
+	eventsFormat = viper.GetString("events")
+	reportFilePath = viper.GetString("report")
+	hostThreadCount = viper.GetInt("host-threads")
+	hostDelay = viper.GetDuration("host-delay")
+	limitRate = viper.GetString("limit-rate")
+	limitRatePerTask = viper.GetString("limit-rate-per-download")
+	segmentCount = viper.GetInt("segments")
+	segmentThreshold = viper.GetString("segment-threshold")
+	minFreeSpace = viper.GetString("min-free-space")
+	spaceCheck = viper.GetString("space-check")
+	downloadOrder = viper.GetString("order")
+	transcripts = viper.GetString("transcripts")
+	shownotesChapters = viper.GetBool("shownotes-chapters")
+	verifyMode = viper.GetString("verify")
+	retryAttempts = viper.GetInt("retry")
+	retryBackoff = viper.GetDuration("retry-backoff")
+	retryMaxBackoff = viper.GetDuration("retry-max-backoff")
+	retryJitter = viper.GetFloat64("retry-jitter")
 
 	// Print loaded configuration items
 	log.Println("Configuration items:")
@@ -217,9 +665,33 @@ func initConfig() {
 	log.Println("-> User agent:", userAgent)
 	log.Println("-> Thread count:", threadCount)
 	log.Println("-> Log folder:", logFolder)
+	log.Println("-> Check content type:", checkContentType)
+	log.Println("-> Feed cache:", useFeedCache)
+	log.Println("-> Feed pages:", feedPages)
+	log.Println("-> Record manifest:", recordManifest)
+	log.Println("-> Dedupe content:", dedupeContent)
+	log.Println("-> Progress mode:", progressMode)
+	log.Println("-> Events format:", eventsFormat)
+	log.Println("-> Report file path:", reportFilePath)
+	log.Println("-> Host threads:", hostThreadCount)
+	log.Println("-> Host delay:", hostDelay)
+	log.Println("-> Limit rate:", limitRate)
+	log.Println("-> Limit rate per download:", limitRatePerTask)
+	log.Println("-> Segments:", segmentCount)
+	log.Println("-> Segment threshold:", segmentThreshold)
+	log.Println("-> Min free space:", minFreeSpace)
+	log.Println("-> Space check:", spaceCheck)
+	log.Println("-> Download order:", downloadOrder)
+	log.Println("-> Transcripts:", transcripts)
+	log.Println("-> Shownotes chapters:", shownotesChapters)
+	log.Println("-> Verify mode:", verifyMode)
+	log.Println("-> Retry attempts:", retryAttempts)
+	log.Println("-> Retry backoff:", retryBackoff)
+	log.Println("-> Retry max backoff:", retryMaxBackoff)
+	log.Println("-> Retry jitter:", retryJitter)
 
 	// Exit when no required configuration items in the configuration file
-	if opmlFilePath == "" && rssListFilePath == "" && rss == "" {
+	if opmlFilePath == "" && rssListFilePath == "" && rss == "" && !resume {
 		log.Fatalln("Please specify at least one argument among \"opml\", \"list\" and \"rss\" in configuration file")
 	}
 }
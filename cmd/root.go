@@ -10,7 +10,7 @@ var rootCmd = &cobra.Command{
 	Short: "PoDownloader is a simple CLI tool to download podcast.",
 	Long: `PoDownloader is a simple CLI tool to download podcast.
 
-This tool will download podcast RSS, podcast cover image, episode audio files, episode cover images and episode shownotes.
+This tool will download podcast RSS, podcast cover image, episode audio files, episode cover images, episode shownotes, transcripts and chapters.
 
 Use the HTTP_PROXY environment variable to set a HTTP or SOCSK5 proxy.`,
 }
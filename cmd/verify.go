@@ -0,0 +1,136 @@
+package main
+
+import (
+	podownloader "PoDownloader"
+	"PoDownloader/podcast"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/vbauerster/mpb/v8"
+	"github.com/vbauerster/mpb/v8/decor"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [output folder]",
+	Short: "Verify downloaded files against the checksum manifests",
+	Long: `Verify downloaded files against the checksum manifests
+
+Every file recorded in the checksum manifests of the podcast folders is hashed again and reported if it is missing, modified or truncated.
+The output folder of the download command is verified if no folder is specified.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  verify,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
+
+func verify(_ *cobra.Command, args []string) {
+	defer func() {
+		if logger != nil {
+			logger.CloseFile()
+		}
+	}()
+	folder := outputFolder
+	if len(args) > 0 {
+		folder = args[0]
+	}
+	manifestDirs, err := podownloader.FindManifestDirs(folder)
+	if err != nil {
+		log.Fatalln("Can not find checksum manifests:", err)
+	}
+	if len(manifestDirs) == 0 {
+		logger.Println(fmt.Sprintf("No checksum manifests found in %s", folder))
+		return
+	}
+
+	var (
+		entriesByDir = make(map[string][]*podownloader.ManifestEntry)
+		totalEntries int
+	)
+	for _, dir := range manifestDirs {
+		entries, err := podownloader.LoadManifest(filepath.Join(dir, podownloader.ManifestFileName))
+		if err != nil {
+			log.Fatalln(fmt.Sprintf("Can not load checksum manifest of %s: %s", dir, err))
+		}
+		entriesByDir[dir] = entries
+		totalEntries += len(entries)
+	}
+	logger.Println(fmt.Sprintf("Verifying %d file(s) of %d podcast(s)", totalEntries, len(manifestDirs)))
+
+	var (
+		problems     []*podownloader.FileCheckResult
+		statusCounts = make(map[podownloader.FileCheckStatus]int)
+	)
+	progressBar := podownloader.NewProgress(podownloader.DetectProgressMode())
+	task := "[Verify]"
+	bar := progressBar.AddBar(
+		int64(totalEntries),
+		mpb.PrependDecorators(
+			decor.Name(task, decor.WC{W: len(task) + 1, C: decor.DidentRight}),
+			decor.CountersNoUnit("%d / %d", decor.WCSyncWidth),
+		),
+		mpb.AppendDecorators(decor.Percentage(decor.WC{W: 5})),
+	)
+	for _, dir := range manifestDirs {
+		for _, entry := range entriesByDir[dir] {
+			result := podownloader.VerifyManifestEntry(dir, entry)
+			statusCounts[result.Status]++
+			if result.Status != podownloader.FileCheckOK {
+				problems = append(problems, result)
+			}
+			bar.Increment()
+		}
+	}
+	progressBar.Wait()
+
+	logger.Println(fmt.Sprintf("%d file(s) ok, %d missing, %d modified, %d truncated, %d unreadable",
+		statusCounts[podownloader.FileCheckOK],
+		statusCounts[podownloader.FileCheckMissing],
+		statusCounts[podownloader.FileCheckModified],
+		statusCounts[podownloader.FileCheckTruncated],
+		statusCounts[podownloader.FileCheckError],
+	))
+	if len(problems) == 0 {
+		return
+	}
+	clearCompletePodcasts(folder, problems)
+	logger.Println(fmt.Sprintf("%d file(s) failed verification:", len(problems)))
+	for index, problem := range problems {
+		switch problem.Status {
+		case podownloader.FileCheckError:
+			logger.Println(fmt.Sprintf("%d. [%s] %s: %s", index+1, problem.Status, problem.Path, problem.Err))
+		case podownloader.FileCheckMissing:
+			logger.Println(fmt.Sprintf("%d. [%s] %s", index+1, problem.Status, problem.Path))
+		case podownloader.FileCheckModified:
+			if problem.Size == problem.Expected.Size {
+				logger.Println(fmt.Sprintf("%d. [%s] %s: checksum %s recorded, %s found", index+1, problem.Status, problem.Path, problem.Expected.SHA256, problem.SHA256))
+				continue
+			}
+			logger.Println(fmt.Sprintf("%d. [%s] %s: %d bytes recorded, %d bytes found", index+1, problem.Status, problem.Path, problem.Expected.Size, problem.Size))
+		default:
+			logger.Println(fmt.Sprintf("%d. [%s] %s: %d bytes recorded, %d bytes found", index+1, problem.Status, problem.Path, problem.Expected.Size, problem.Size))
+		}
+	}
+	logger.CloseFile()
+	os.Exit(1)
+}
+
+// clearCompletePodcasts clears the complete marks in the feed cache of specified folder of the podcasts
+// that own the files failed verification, so that the next download run plans them again
+func clearCompletePodcasts(folder string, problems []*podownloader.FileCheckResult) {
+	paths := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		paths = append(paths, problem.Path)
+	}
+	feedCache := podcast.NewFeedCache(filepath.Join(folder, podcast.FeedCacheDirName))
+	cleared, err := feedCache.ClearComplete(paths)
+	if err != nil {
+		logger.Println(fmt.Sprintf("Failed to update the feed cache of %s: %s", folder, err))
+	}
+	if cleared > 0 {
+		logger.Println(fmt.Sprintf("%d podcast(s) will be checked again by the next download run", cleared))
+	}
+}
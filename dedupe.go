@@ -0,0 +1,195 @@
+package podownloader
+
+import (
+	"PoDownloader/util"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// dedupeQueueItems removes the URLDownloadTask items whose URL is the same as an earlier item,
+// the destinations of the removed tasks are added to URLDownloadTask.LinkDests of the earlier task
+// so that each URL is downloaded only once
+func dedupeQueueItems(items []*queueItem) []*queueItem {
+	var (
+		dedupedItems = make([]*queueItem, 0, len(items))
+		tasksByURL   = make(map[string]*URLDownloadTask)
+	)
+	for _, item := range items {
+		urlDownloadTask, ok := item.task.(*URLDownloadTask)
+		if !ok || urlDownloadTask.URL == "" {
+			dedupedItems = append(dedupedItems, item)
+			continue
+		}
+		firstTask, ok := tasksByURL[urlDownloadTask.URL]
+		if !ok {
+			urlDownloadTask.LinkDests = nil
+			tasksByURL[urlDownloadTask.URL] = urlDownloadTask
+			dedupedItems = append(dedupedItems, item)
+			continue
+		}
+		if urlDownloadTask.Dest != firstTask.Dest {
+			firstTask.LinkDests = append(firstTask.LinkDests, urlDownloadTask.Dest)
+		}
+	}
+	return dedupedItems
+}
+
+// getFirstURLDests returns the destination of the first URLDownloadTask of each URL in specified download tasks
+func getFirstURLDests(podcastDownloadTasks []*PodcastDownloadTask) map[string]string {
+	firstDests := make(map[string]string)
+	for podcastIndex, podcastDownloadTask := range podcastDownloadTasks {
+		for _, item := range podcastDownloadTask.queueItems(podcastIndex) {
+			urlDownloadTask, ok := item.task.(*URLDownloadTask)
+			if !ok || urlDownloadTask.URL == "" {
+				continue
+			}
+			if _, ok := firstDests[urlDownloadTask.URL]; !ok {
+				firstDests[urlDownloadTask.URL] = urlDownloadTask.Dest
+			}
+		}
+	}
+	return firstDests
+}
+
+// linkDownloadedDuplicates sets URLDownloadTask.LinkSrc of the tasks left in specified download tasks
+// whose URL was first planned for a destination that has been removed as already downloaded,
+// firstDests are the destinations returned by getFirstURLDests before the downloaded tasks were removed
+func linkDownloadedDuplicates(podcastDownloadTasks []*PodcastDownloadTask, firstDests map[string]string) {
+	var (
+		urlDownloadTasks []*URLDownloadTask
+		leftDests        = make(map[string]bool)
+	)
+	for podcastIndex, podcastDownloadTask := range podcastDownloadTasks {
+		for _, item := range podcastDownloadTask.queueItems(podcastIndex) {
+			if urlDownloadTask, ok := item.task.(*URLDownloadTask); ok && urlDownloadTask.URL != "" {
+				urlDownloadTasks = append(urlDownloadTasks, urlDownloadTask)
+				leftDests[urlDownloadTask.Dest] = true
+			}
+		}
+	}
+	for _, urlDownloadTask := range urlDownloadTasks {
+		firstDest := firstDests[urlDownloadTask.URL]
+		if firstDest != "" && !leftDests[firstDest] {
+			urlDownloadTask.LinkSrc = firstDest
+		}
+	}
+}
+
+// linkFromSource hard links (or copies) URLDownloadTask.LinkSrc to URLDownloadTask.Dest
+func (c *URLDownloadTask) linkFromSource() error {
+	err := util.EnsureDirAll(filepath.Dir(c.Dest))
+	if err != nil {
+		return err
+	}
+	return util.LinkOrCopyFile(c.LinkSrc, c.Dest)
+}
+
+// linkDuplicates hard links (or copies) URLDownloadTask.Dest to URLDownloadTask.LinkDests
+func (c *URLDownloadTask) linkDuplicates() error {
+	for _, linkDest := range c.LinkDests {
+		err := util.EnsureDirAll(filepath.Dir(linkDest))
+		if err != nil {
+			return err
+		}
+		err = util.LinkOrCopyFile(c.Dest, linkDest)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ContentIndex indexes the files on disk by their SHA-256 checksums, so that a downloaded file with the same content
+// as an existing file can be replaced by a hard link to it. It is safe for concurrent use
+type ContentIndex struct {
+	paths map[string]string
+	lock  *sync.Mutex
+}
+
+// NewContentIndex returns an empty ContentIndex instance
+func NewContentIndex() *ContentIndex {
+	return &ContentIndex{
+		paths: make(map[string]string),
+		lock:  &sync.Mutex{},
+	}
+}
+
+// LoadContentIndex returns a ContentIndex of the files recorded in the checksum manifests under specified root directory,
+// the files that do not exist or whose sizes have changed are not indexed
+func LoadContentIndex(root string) (*ContentIndex, error) {
+	contentIndex := NewContentIndex()
+	manifestDirs, err := FindManifestDirs(root)
+	if os.IsNotExist(err) {
+		return contentIndex, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range manifestDirs {
+		entries, err := LoadManifest(filepath.Join(dir, ManifestFileName))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir, filepath.FromSlash(entry.Path))
+			if size, err := util.GetFileSize(path); err == nil && size == entry.Size {
+				contentIndex.paths[entry.SHA256] = path
+			}
+		}
+	}
+	return contentIndex, nil
+}
+
+// Dedupe replaces specified file with a hard link to an indexed file with the same checksum and size,
+// the file is indexed if there is no such file. Returns the path of the linked file, empty if the file is not replaced.
+// The indexed file is hashed again before linking, so a file modified after it was indexed is never linked.
+// The index is not locked while hashing, so other workers are not blocked by hashing large files
+func (i *ContentIndex) Dedupe(path string, digest string, size int64) (string, error) {
+	existingPath := i.getCandidate(path, digest, size)
+	if existingPath == "" {
+		return "", nil
+	}
+	existingDigest, _, err := util.GetFileSHA256(existingPath)
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if i.paths[digest] != existingPath {
+		// The index has been changed by another worker while hashing
+		return "", nil
+	}
+	if err != nil || existingDigest != digest {
+		i.paths[digest] = path
+		return "", nil
+	}
+	// Check the candidate again in case it has been replaced while hashing
+	if existingStat, err := os.Stat(existingPath); err != nil || existingStat.Size() != size {
+		i.paths[digest] = path
+		return "", nil
+	}
+	err = util.LinkFile(existingPath, path)
+	if err != nil {
+		return "", err
+	}
+	return existingPath, nil
+}
+
+// getCandidate returns the indexed file with specified checksum and size that specified file can be linked to,
+// specified file is indexed and an empty string is returned if there is no such file
+func (i *ContentIndex) getCandidate(path string, digest string, size int64) string {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	existingPath, ok := i.paths[digest]
+	if !ok || existingPath == path {
+		i.paths[digest] = path
+		return ""
+	}
+	existingStat, err := os.Stat(existingPath)
+	if err != nil || existingStat.Size() != size {
+		i.paths[digest] = path
+		return ""
+	}
+	if stat, err := os.Stat(path); err == nil && os.SameFile(stat, existingStat) {
+		return ""
+	}
+	return existingPath
+}
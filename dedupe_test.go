@@ -0,0 +1,125 @@
+package podownloader
+
+import (
+	"PoDownloader/logger"
+	"PoDownloader/util"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDedupeQueueItems(t *testing.T) {
+	cover := &URLDownloadTask{JobType: JobTypeCover, URL: "http://example.com/cover.jpg", Dest: "foo/cover.jpg", LinkDests: []string{"stale"}}
+	episodeCover := &URLDownloadTask{JobType: JobTypeCover, URL: "http://example.com/cover.jpg", Dest: "foo/episode/cover.jpg"}
+	enclosure := &URLDownloadTask{JobType: JobTypeEnclosure, URL: "http://example.com/1.mp3", Dest: "foo/episode/1.mp3"}
+	republished := &URLDownloadTask{JobType: JobTypeEnclosure, URL: "http://example.com/1.mp3", Dest: "bar/episode/1.mp3"}
+	shownotes := &TextSaveTask{JobType: JobTypeShownotes, Dest: "foo/episode/shownotes.html"}
+	items := dedupeQueueItems([]*queueItem{{task: cover}, {task: episodeCover}, {task: shownotes}, {task: enclosure}, {task: republished}})
+	assert.Equal(t, 3, len(items))
+	assert.Equal(t, cover, items[0].task)
+	assert.Equal(t, shownotes, items[1].task)
+	assert.Equal(t, enclosure, items[2].task)
+	assert.Equal(t, []string{"foo/episode/cover.jpg"}, cover.LinkDests)
+	assert.Equal(t, []string{"bar/episode/1.mp3"}, enclosure.LinkDests)
+}
+
+func TestDownloadQueue_StartDownloadDuplicateURL(t *testing.T) {
+	var requests int32
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.ServeContent(writer, request, "cover.jpg", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+	root := t.TempDir()
+	downloadQueue := NewDownloadQueueFromDownloadTasks([]*PodcastDownloadTask{
+		{CoverDownloadTask: &URLDownloadTask{JobType: JobTypeCover, URL: server.URL, Dest: filepath.Join(root, "foo", "cover.jpg")}},
+		{CoverDownloadTask: &URLDownloadTask{JobType: JobTypeCover, URL: server.URL, Dest: filepath.Join(root, "bar", "cover.jpg")}},
+	}, DownloadOrderFeed)
+	assert.Equal(t, 1, downloadQueue.Length())
+
+	testLogger, _ := logger.NewLogger("")
+	downloadResult := downloadQueue.StartDownload(2, &http.Client{}, testLogger, DefaultDownloadOptions())
+	assert.Equal(t, 0, len(downloadResult.FailedTasks))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	for _, podcastDir := range []string{"foo", "bar"} {
+		downloaded, err := os.ReadFile(filepath.Join(root, podcastDir, "cover.jpg"))
+		assert.Nil(t, err)
+		assert.Equal(t, content, downloaded)
+	}
+}
+
+func TestContentIndex_Dedupe(t *testing.T) {
+	root := t.TempDir()
+	existingPath := filepath.Join(root, "foo", "1.mp3")
+	newPath := filepath.Join(root, "bar", "1.mp3")
+	otherPath := filepath.Join(root, "bar", "2.mp3")
+	assert.Nil(t, os.MkdirAll(filepath.Dir(existingPath), 0755))
+	assert.Nil(t, os.MkdirAll(filepath.Dir(newPath), 0755))
+	assert.Nil(t, os.WriteFile(existingPath, []byte("episode"), 0644))
+	manifestWriter := NewManifestWriter(root)
+	assert.Nil(t, manifestWriter.Record(existingPath))
+	assert.Nil(t, manifestWriter.Close())
+
+	contentIndex, err := LoadContentIndex(root)
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(newPath, []byte("episode"), 0644))
+	digest, size, err := util.GetFileSHA256(newPath)
+	assert.Nil(t, err)
+	linkedPath, err := contentIndex.Dedupe(newPath, digest, size)
+	assert.Nil(t, err)
+	assert.Equal(t, existingPath, linkedPath)
+	existingStat, _ := os.Stat(existingPath)
+	newStat, _ := os.Stat(newPath)
+	assert.True(t, os.SameFile(existingStat, newStat))
+
+	// A file with different content is indexed instead of linked
+	assert.Nil(t, os.WriteFile(otherPath, []byte("another episode"), 0644))
+	digest, size, err = util.GetFileSHA256(otherPath)
+	assert.Nil(t, err)
+	linkedPath, err = contentIndex.Dedupe(otherPath, digest, size)
+	assert.Nil(t, err)
+	assert.Equal(t, "", linkedPath)
+	assert.Equal(t, otherPath, contentIndex.paths[digest])
+}
+
+func TestDownloadQueue_StartDownloadDuplicateURLDownloaded(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.NotFound(writer, request)
+	}))
+	defer server.Close()
+	root := t.TempDir()
+	downloadedPath := filepath.Join(root, "foo", "cover.jpg")
+	assert.Nil(t, util.EnsureDirAll(filepath.Dir(downloadedPath)))
+	assert.Nil(t, os.WriteFile(downloadedPath, []byte("cover"), 0644))
+	podcastDownloadTasks := []*PodcastDownloadTask{
+		{EpisodeDownloadTasks: []*EpisodeDownloadTask{{CoverDownloadTask: &URLDownloadTask{JobType: JobTypeCover, URL: server.URL, Dest: downloadedPath}}}},
+		{EpisodeDownloadTasks: []*EpisodeDownloadTask{{CoverDownloadTask: &URLDownloadTask{JobType: JobTypeCover, URL: server.URL, Dest: filepath.Join(root, "bar", "cover.jpg")}}}},
+		{EpisodeDownloadTasks: []*EpisodeDownloadTask{{CoverDownloadTask: &URLDownloadTask{JobType: JobTypeCover, URL: server.URL, Dest: filepath.Join(root, "baz", "cover.jpg")}}}},
+	}
+	downloadTaskIterator := NewDownloadTaskIterator(podcastDownloadTasks)
+	downloadTaskIterator.ProgressMode = ProgressModePlain
+	downloadTaskIterator.RemoveDownloadedTask(1, &http.Client{}, VerifyModeExist)
+	assert.Nil(t, podcastDownloadTasks[0].EpisodeDownloadTasks[0].CoverDownloadTask)
+	assert.Equal(t, downloadedPath, podcastDownloadTasks[1].EpisodeDownloadTasks[0].CoverDownloadTask.LinkSrc)
+
+	downloadQueue := NewDownloadQueueFromDownloadTasks(podcastDownloadTasks, DownloadOrderFeed)
+	assert.Equal(t, 1, downloadQueue.Length())
+	testLogger, _ := logger.NewLogger("")
+	downloadResult := downloadQueue.StartDownload(1, &http.Client{}, testLogger, DefaultDownloadOptions())
+	assert.Equal(t, 0, len(downloadResult.FailedTasks))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+	for _, podcastDir := range []string{"bar", "baz"} {
+		content, err := os.ReadFile(filepath.Join(root, podcastDir, "cover.jpg"))
+		assert.Nil(t, err)
+		assert.Equal(t, "cover", string(content))
+	}
+}
@@ -0,0 +1,87 @@
+package podownloader
+
+import (
+	"PoDownloader/util"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"syscall"
+)
+
+// diskSpaceCheckInterval is the number of bytes written by a download between two free disk space checks
+const diskSpaceCheckInterval = 8 * 1024 * 1024
+
+// InsufficientDiskSpaceError is returned when the free disk space of the filesystem containing Path
+// is less than the required bytes
+type InsufficientDiskSpaceError struct {
+	Path     string
+	Free     int64
+	Required int64
+}
+
+// Error implements the error interface
+func (e *InsufficientDiskSpaceError) Error() string {
+	return fmt.Sprintf("insufficient disk space for %s: %s free, %s required", e.Path, util.FormatByteSize(e.Free), util.FormatByteSize(e.Required))
+}
+
+// CheckDiskSpace returns an InsufficientDiskSpaceError if the free disk space of the filesystem containing specified path
+// is less than required bytes, nil is returned if the free disk space can not be determined
+func CheckDiskSpace(path string, required int64) error {
+	free, err := util.GetFreeDiskSpace(path)
+	if err != nil {
+		return nil
+	}
+	if free < required {
+		return &InsufficientDiskSpaceError{Path: path, Free: free, Required: required}
+	}
+	return nil
+}
+
+// IsDiskSpaceError returns whether the error is caused by insufficient disk space
+func IsDiskSpaceError(err error) bool {
+	var diskSpaceErr *InsufficientDiskSpaceError
+	return errors.As(err, &diskSpaceErr) || errors.Is(err, syscall.ENOSPC)
+}
+
+// diskSpaceGuard checks the free disk space every diskSpaceCheckInterval bytes written by a download,
+// so that the download fails before the free disk space drops below the reserve. It is safe for concurrent use
+// and a nil diskSpaceGuard never fails
+type diskSpaceGuard struct {
+	path      string
+	reserve   int64
+	unchecked int64
+}
+
+// newDiskSpaceGuard returns a diskSpaceGuard of the filesystem containing specified path,
+// returns nil if reserve is not greater than 0
+func newDiskSpaceGuard(path string, reserve int64) *diskSpaceGuard {
+	if reserve <= 0 {
+		return nil
+	}
+	return &diskSpaceGuard{path: path, reserve: reserve}
+}
+
+// add reports n bytes written, an InsufficientDiskSpaceError is returned if the free disk space is below the reserve
+func (g *diskSpaceGuard) add(n int) error {
+	if g == nil || atomic.AddInt64(&g.unchecked, int64(n)) < diskSpaceCheckInterval {
+		return nil
+	}
+	atomic.StoreInt64(&g.unchecked, 0)
+	return CheckDiskSpace(g.path, g.reserve)
+}
+
+// diskSpaceWriter is an io.Writer that checks the free disk space with a diskSpaceGuard after writing
+type diskSpaceWriter struct {
+	writer io.Writer
+	guard  *diskSpaceGuard
+}
+
+// Write implements the io.Writer interface
+func (w *diskSpaceWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	if err == nil {
+		err = w.guard.add(n)
+	}
+	return n, err
+}
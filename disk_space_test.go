@@ -0,0 +1,60 @@
+package podownloader
+
+import (
+	"PoDownloader/logger"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCheckDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, CheckDiskSpace(filepath.Join(dir, "foo.mp3"), 0))
+	err := CheckDiskSpace(filepath.Join(dir, "foo.mp3"), math.MaxInt64)
+	assert.True(t, IsDiskSpaceError(err))
+}
+
+func TestDiskSpaceWriter(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	// A nil guard never fails
+	writer := &diskSpaceWriter{writer: buffer, guard: newDiskSpaceGuard(t.TempDir(), 0)}
+	_, err := writer.Write(make([]byte, diskSpaceCheckInterval))
+	assert.Nil(t, err)
+
+	writer = &diskSpaceWriter{writer: buffer, guard: newDiskSpaceGuard(t.TempDir(), math.MaxInt64)}
+	_, err = writer.Write(make([]byte, diskSpaceCheckInterval-1))
+	assert.Nil(t, err)
+	_, err = writer.Write(make([]byte, 1))
+	assert.True(t, IsDiskSpaceError(err))
+	assert.Equal(t, 2*diskSpaceCheckInterval, buffer.Len())
+}
+
+func TestDownloadQueue_StartDownloadLowDiskSpace(t *testing.T) {
+	var executed int32
+	dir := t.TempDir()
+	downloadQueue := &DownloadQueue{lock: &sync.Mutex{}}
+	downloadQueue.EnQueue(&fakeTask{dest: filepath.Join(dir, "a"), executed: &executed})
+	downloadQueue.EnQueue(&fakeTask{dest: filepath.Join(dir, "b"), executed: &executed})
+
+	testLogger, _ := logger.NewLogger("")
+	options := DefaultDownloadOptions()
+	options.MinFreeSpace = math.MaxInt64
+	events := &recordingEventSink{}
+	options.Events = events
+	downloadResult := downloadQueue.StartDownload(1, &http.Client{}, testLogger, options)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&executed))
+	assert.True(t, downloadResult.LowDiskSpace)
+	assert.True(t, downloadResult.IsInterrupted())
+	assert.Empty(t, downloadResult.FailedTasks)
+	assert.Equal(t, 2, len(downloadResult.UnstartedTasks))
+	// The task failing the disk space check is reported as unstarted without being started
+	assert.Equal(t, 1, len(events.events))
+	assert.Equal(t, EventTaskFailed, events.events[0].Type)
+	assert.Equal(t, string(TaskResultUnstarted), events.events[0].Status)
+	assert.Equal(t, string(ErrorClassDiskSpace), events.events[0].ErrorClass)
+}
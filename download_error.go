@@ -0,0 +1,107 @@
+package podownloader
+
+import (
+	"PoDownloader/util"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// HTTPStatusError is returned when the server responds a download request with a non-2xx status code
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+	// RetryAfter is the delay requested by the Retry-After response header, 0 if the header is absent
+	RetryAfter time.Duration
+}
+
+// newHTTPStatusError returns an HTTPStatusError instance built from specified response
+func newHTTPStatusError(url string, resp *http.Response) *HTTPStatusError {
+	return &HTTPStatusError{
+		URL:        url,
+		StatusCode: resp.StatusCode,
+		RetryAfter: util.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// Error implements the error interface
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected HTTP status %d %s from %s", e.StatusCode, http.StatusText(e.StatusCode), e.URL)
+}
+
+// ContentTypeError is returned when the response Content-Type does not match
+// the content type families expected by the job type of a download task
+type ContentTypeError struct {
+	URL              string
+	ContentType      string
+	ExpectedFamilies []string
+}
+
+// Error implements the error interface
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("unexpected Content-Type %q from %s, expected %s", e.ContentType, e.URL, strings.Join(e.ExpectedFamilies, " or "))
+}
+
+// ErrorClass is the class of an error, it is used to group failures in reports
+type ErrorClass string
+
+// Error classes returned by ClassifyError
+const (
+	ErrorClassHTTPStatus  ErrorClass = "http-status"
+	ErrorClassContentType ErrorClass = "content-type"
+	ErrorClassTimeout     ErrorClass = "timeout"
+	ErrorClassNetwork     ErrorClass = "network"
+	ErrorClassTruncated   ErrorClass = "truncated"
+	ErrorClassCanceled    ErrorClass = "canceled"
+	ErrorClassDiskSpace   ErrorClass = "disk-space"
+	ErrorClassFileSystem  ErrorClass = "filesystem"
+	ErrorClassFeedParse   ErrorClass = "feed-parse"
+	ErrorClassEmptyFeed   ErrorClass = "empty-feed"
+	ErrorClassOther       ErrorClass = "other"
+)
+
+// ClassifyError returns the class of specified error, empty if err is nil
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ""
+	}
+	var (
+		statusErr      *HTTPStatusError
+		contentTypeErr *ContentTypeError
+		netErr         net.Error
+		pathErr        *fs.PathError
+		linkErr        *os.LinkError
+	)
+	feedErr := GetFeedError(err)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCanceled
+	case feedErr != nil && feedErr.Stage == FeedStageParse:
+		return ErrorClassFeedParse
+	case feedErr != nil && feedErr.Stage == FeedStageEmpty:
+		return ErrorClassEmptyFeed
+	case errors.As(err, &statusErr):
+		return ErrorClassHTTPStatus
+	case errors.As(err, &contentTypeErr):
+		return ErrorClassContentType
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		return ErrorClassTimeout
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorClassTruncated
+	case IsDiskSpaceError(err):
+		return ErrorClassDiskSpace
+	case errors.As(err, &pathErr) || errors.As(err, &linkErr):
+		return ErrorClassFileSystem
+	case errors.As(err, &netErr) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE):
+		return ErrorClassNetwork
+	}
+	return ErrorClassOther
+}
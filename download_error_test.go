@@ -0,0 +1,27 @@
+package podownloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	assert.Equal(t, ErrorClass(""), ClassifyError(nil))
+	assert.Equal(t, ErrorClassHTTPStatus, ClassifyError(fmt.Errorf("wrapped: %w", &HTTPStatusError{StatusCode: 500})))
+	assert.Equal(t, ErrorClassContentType, ClassifyError(&ContentTypeError{}))
+	assert.Equal(t, ErrorClassCanceled, ClassifyError(context.Canceled))
+	assert.Equal(t, ErrorClassTimeout, ClassifyError(context.DeadlineExceeded))
+	assert.Equal(t, ErrorClassTruncated, ClassifyError(io.ErrUnexpectedEOF))
+	assert.Equal(t, ErrorClassNetwork, ClassifyError(syscall.ECONNRESET))
+	_, err := os.Open("/path/does/not/exist")
+	assert.Equal(t, ErrorClassFileSystem, ClassifyError(err))
+	assert.Equal(t, ErrorClassDiskSpace, ClassifyError(&os.PathError{Op: "write", Path: "foo", Err: syscall.ENOSPC}))
+	assert.Equal(t, ErrorClassDiskSpace, ClassifyError(&InsufficientDiskSpaceError{}))
+	assert.Equal(t, ErrorClassOther, ClassifyError(errors.New("foobar")))
+}
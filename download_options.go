@@ -0,0 +1,67 @@
+package podownloader
+
+import (
+	"PoDownloader/util"
+	"time"
+)
+
+// DownloadOptions contains the options that control how download tasks are executed
+type DownloadOptions struct {
+	// CheckContentType enables checking whether the response Content-Type matches the job type of a download task
+	CheckContentType bool
+	// RetryPolicy controls how failed download tasks are retried
+	RetryPolicy *RetryPolicy
+	// MaxTasksPerHost is the maximum number of concurrent download tasks per host, 0 means no limit
+	MaxTasksPerHost int
+	// HostInterval is the minimum interval between two download tasks started on the same host
+	HostInterval time.Duration
+	// RateLimiter limits the aggregate download speed of all download tasks, nil means no limit
+	RateLimiter *util.RateLimiter
+	// PerDownloadRateLimit is the maximum download speed of each download task in bytes per second, 0 means no limit
+	PerDownloadRateLimit int64
+	// SegmentCount is the maximum number of connections used to download an enclosure in segments,
+	// 0 or 1 disables segmented downloading
+	SegmentCount int
+	// SegmentThreshold is the minimum size in bytes of an enclosure downloaded in segments
+	SegmentThreshold int64
+	// MinFreeSpace is the free disk space in bytes to keep on the filesystem of the download destinations,
+	// download tasks fail and no new tasks are started when the free disk space drops below it, 0 disables the guard
+	MinFreeSpace int64
+	// Manifest records the checksums of the files downloaded by URLDownloadTask and TextSaveTask, nil disables recording
+	Manifest *ManifestWriter
+	// ContentIndex replaces downloaded files with hard links to the files with the same content, nil disables deduplication
+	ContentIndex *ContentIndex
+	// ProgressMode determines whether the progress bars of the download tasks are displayed
+	ProgressMode ProgressMode
+	// Events receives the events of the download tasks, nil if the events are not emitted
+	Events EventSink
+}
+
+// DefaultDownloadOptions returns the default download options
+func DefaultDownloadOptions() *DownloadOptions {
+	return &DownloadOptions{
+		CheckContentType:     false,
+		RetryPolicy:          DefaultRetryPolicy(),
+		MaxTasksPerHost:      0,
+		HostInterval:         0,
+		RateLimiter:          nil,
+		PerDownloadRateLimit: 0,
+		SegmentCount:         0,
+		SegmentThreshold:     100 * 1024 * 1024,
+		MinFreeSpace:         0,
+		Manifest:             nil,
+		ContentIndex:         nil,
+		ProgressMode:         ProgressModeBar,
+		Events:               nil,
+	}
+}
+
+// rateLimiters returns the rate limiters that apply to a single download task,
+// a new per-download rate limiter is created for each call
+func (o *DownloadOptions) rateLimiters() []*util.RateLimiter {
+	rateLimiters := []*util.RateLimiter{o.RateLimiter}
+	if o.PerDownloadRateLimit > 0 {
+		rateLimiters = append(rateLimiters, util.NewRateLimiter(o.PerDownloadRateLimit))
+	}
+	return rateLimiters
+}
@@ -0,0 +1,102 @@
+package podownloader
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DownloadOrder determines the order in which the tasks of DownloadQueue are started
+type DownloadOrder string
+
+const (
+	// DownloadOrderFeed starts tasks podcast by podcast in feed order
+	DownloadOrderFeed DownloadOrder = "feed"
+	// DownloadOrderNewest starts tasks of the newest episodes of all podcasts first
+	DownloadOrderNewest DownloadOrder = "newest"
+	// DownloadOrderOldest starts tasks of the oldest episodes of all podcasts first
+	DownloadOrderOldest DownloadOrder = "oldest"
+	// DownloadOrderRoundRobin takes turns between podcasts, newest episodes first within each podcast
+	DownloadOrderRoundRobin DownloadOrder = "round-robin"
+	// DownloadOrderMetadataFirst starts RSS, cover, shownotes, transcript and chapters tasks before enclosure tasks
+	DownloadOrderMetadataFirst DownloadOrder = "metadata-first"
+)
+
+// ParseDownloadOrder returns the DownloadOrder corresponding to specified text
+func ParseDownloadOrder(text string) (DownloadOrder, error) {
+	switch downloadOrder := DownloadOrder(text); downloadOrder {
+	case DownloadOrderFeed, DownloadOrderNewest, DownloadOrderOldest, DownloadOrderRoundRobin, DownloadOrderMetadataFirst:
+		return downloadOrder, nil
+	}
+	return "", fmt.Errorf("unknown download order: %s, available orders: feed, newest, oldest, round-robin, metadata-first", text)
+}
+
+// queueItem is an item of DownloadQueue, it holds a task and the information used to order the tasks
+type queueItem struct {
+	task Task
+	// podcastIndex is the index of the podcast the task belongs to
+	podcastIndex int
+	// pubDate is the publish date of the episode the task belongs to, nil for podcast level tasks
+	pubDate *time.Time
+}
+
+// isNewerThan returns whether the task of queueItem belongs to a newer episode than other,
+// podcast level tasks and tasks of episodes without publish date are treated as the newest
+func (qi *queueItem) isNewerThan(other *queueItem) bool {
+	if qi.pubDate == nil || other.pubDate == nil {
+		return qi.pubDate == nil && other.pubDate != nil
+	}
+	return qi.pubDate.After(*other.pubDate)
+}
+
+// isOlderThan returns whether the task of queueItem belongs to an older episode than other,
+// podcast level tasks and tasks of episodes without publish date are treated as the oldest
+func (qi *queueItem) isOlderThan(other *queueItem) bool {
+	if qi.pubDate == nil || other.pubDate == nil {
+		return qi.pubDate == nil && other.pubDate != nil
+	}
+	return qi.pubDate.Before(*other.pubDate)
+}
+
+// sortQueueItems returns the queue items sorted by specified DownloadOrder,
+// items are expected to be in feed order, and the relative order of equal items is preserved
+func sortQueueItems(items []*queueItem, downloadOrder DownloadOrder) []*queueItem {
+	switch downloadOrder {
+	case DownloadOrderNewest:
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].isNewerThan(items[j])
+		})
+	case DownloadOrderOldest:
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].isOlderThan(items[j])
+		})
+	case DownloadOrderMetadataFirst:
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].task.Kind() != JobTypeEnclosure && items[j].task.Kind() == JobTypeEnclosure
+		})
+	case DownloadOrderRoundRobin:
+		var (
+			podcastIndexes []int
+			podcastItems   = make(map[int][]*queueItem)
+		)
+		for _, item := range items {
+			if _, ok := podcastItems[item.podcastIndex]; !ok {
+				podcastIndexes = append(podcastIndexes, item.podcastIndex)
+			}
+			podcastItems[item.podcastIndex] = append(podcastItems[item.podcastIndex], item)
+		}
+		for _, podcastIndex := range podcastIndexes {
+			sortQueueItems(podcastItems[podcastIndex], DownloadOrderNewest)
+		}
+		sortedItems := make([]*queueItem, 0, len(items))
+		for round := 0; len(sortedItems) < len(items); round++ {
+			for _, podcastIndex := range podcastIndexes {
+				if round < len(podcastItems[podcastIndex]) {
+					sortedItems = append(sortedItems, podcastItems[podcastIndex][round])
+				}
+			}
+		}
+		return sortedItems
+	}
+	return items
+}
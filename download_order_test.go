@@ -0,0 +1,63 @@
+package podownloader
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newOrderTestDownloadTasks() []*PodcastDownloadTask {
+	day := func(d int) *time.Time {
+		date := time.Date(2021, 7, d, 0, 0, 0, 0, time.UTC)
+		return &date
+	}
+	return []*PodcastDownloadTask{
+		{
+			RSSDownloadTask: &URLDownloadTask{Dest: "a/rss.xml", JobType: JobTypeRSS},
+			EpisodeDownloadTasks: []*EpisodeDownloadTask{
+				{PubDate: day(1), EnclosureDownloadTasks: []*URLDownloadTask{{Dest: "a/1.mp3", JobType: JobTypeEnclosure}}},
+				{PubDate: day(3), EnclosureDownloadTasks: []*URLDownloadTask{{Dest: "a/3.mp3", JobType: JobTypeEnclosure}}},
+			},
+		},
+		{
+			RSSDownloadTask: &URLDownloadTask{Dest: "b/rss.xml", JobType: JobTypeRSS},
+			EpisodeDownloadTasks: []*EpisodeDownloadTask{
+				{
+					PubDate:                day(2),
+					EnclosureDownloadTasks: []*URLDownloadTask{{Dest: "b/2.mp3", JobType: JobTypeEnclosure}},
+					ShownotesDownloadTask:  &TextSaveTask{Dest: "b/2.html", JobType: JobTypeShownotes},
+				},
+			},
+		},
+	}
+}
+
+func getQueueDestinations(downloadQueue *DownloadQueue) []string {
+	var destinations []string
+	for !downloadQueue.IsEmpty() {
+		task, _ := downloadQueue.DeQueue()
+		destinations = append(destinations, task.Destination())
+	}
+	return destinations
+}
+
+func TestNewDownloadQueueFromDownloadTasks(t *testing.T) {
+	for downloadOrder, expected := range map[DownloadOrder][]string{
+		DownloadOrderFeed:          {"a/rss.xml", "a/1.mp3", "a/3.mp3", "b/rss.xml", "b/2.html", "b/2.mp3"},
+		DownloadOrderNewest:        {"a/rss.xml", "b/rss.xml", "a/3.mp3", "b/2.html", "b/2.mp3", "a/1.mp3"},
+		DownloadOrderOldest:        {"a/rss.xml", "b/rss.xml", "a/1.mp3", "b/2.html", "b/2.mp3", "a/3.mp3"},
+		DownloadOrderRoundRobin:    {"a/rss.xml", "b/rss.xml", "a/3.mp3", "b/2.html", "a/1.mp3", "b/2.mp3"},
+		DownloadOrderMetadataFirst: {"a/rss.xml", "b/rss.xml", "b/2.html", "a/1.mp3", "a/3.mp3", "b/2.mp3"},
+	} {
+		downloadQueue := NewDownloadQueueFromDownloadTasks(newOrderTestDownloadTasks(), downloadOrder)
+		assert.Equal(t, expected, getQueueDestinations(downloadQueue), string(downloadOrder))
+	}
+}
+
+func TestParseDownloadOrder(t *testing.T) {
+	downloadOrder, err := ParseDownloadOrder("round-robin")
+	assert.Nil(t, err)
+	assert.Equal(t, DownloadOrderRoundRobin, downloadOrder)
+	_, err = ParseDownloadOrder("foobar")
+	assert.NotNil(t, err)
+}
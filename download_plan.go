@@ -0,0 +1,180 @@
+package podownloader
+
+import (
+	"PoDownloader/util"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"text/tabwriter"
+)
+
+// Sources of the estimated size of a planned task
+const (
+	// SizeSourceFeed means the size is declared in the feed
+	SizeSourceFeed = "feed"
+	// SizeSourceHead means the size is the Content-Length of an HTTP HEAD request
+	SizeSourceHead = "head"
+	// SizeSourceText means the size is the size of the text to save
+	SizeSourceText = "text"
+	// SizeSourceDuplicate means the URL is downloaded by another task and the file is linked, so no size is added
+	SizeSourceDuplicate = "duplicate"
+	// SizeSourceUnknown means the size can not be estimated
+	SizeSourceUnknown = "unknown"
+)
+
+// PlannedTask is a task that would be executed by a download run
+type PlannedTask struct {
+	JobType string `json:"jobType"`
+	URL     string `json:"url,omitempty"`
+	Dest    string `json:"dest"`
+	// Size is the estimated size in bytes, 0 if unknown
+	Size       int64  `json:"size"`
+	SizeSource string `json:"sizeSource"`
+}
+
+// PodcastPlan contains the planned tasks of a podcast
+type PodcastPlan struct {
+	PodcastTitle string         `json:"podcastTitle"`
+	BaseDestDir  string         `json:"baseDestDir"`
+	Tasks        []*PlannedTask `json:"tasks"`
+	// TotalSize is the sum of the estimated sizes of the tasks
+	TotalSize int64 `json:"totalSize"`
+	// UnknownSizeCount is the number of tasks whose size can not be estimated
+	UnknownSizeCount int `json:"unknownSizeCount"`
+}
+
+// DownloadPlan is the plan of a download run, it lists every task that would be executed with its estimated size
+type DownloadPlan struct {
+	Podcasts         []*PodcastPlan `json:"podcasts"`
+	TotalTasks       int            `json:"totalTasks"`
+	TotalSize        int64          `json:"totalSize"`
+	UnknownSizeCount int            `json:"unknownSizeCount"`
+}
+
+// NewDownloadPlan returns the plan of specified download tasks, the sizes of the tasks are estimated
+// from the lengths declared in the feeds. If headRequest is true, remote files without declared length
+// are requested by HTTP HEAD requests in threadCount goroutines, otherwise their sizes are unknown
+func NewDownloadPlan(podcastDownloadTasks []*PodcastDownloadTask, httpClient *http.Client, threadCount int, headRequest bool) *DownloadPlan {
+	var (
+		downloadPlan = &DownloadPlan{}
+		headTasks    []*PlannedTask
+		plannedURLs  = make(map[string]bool)
+	)
+	for podcastIndex, podcastDownloadTask := range podcastDownloadTasks {
+		podcastPlan := &PodcastPlan{
+			PodcastTitle: podcastDownloadTask.PodcastTitle,
+			BaseDestDir:  podcastDownloadTask.BaseDestDir,
+		}
+		for _, item := range podcastDownloadTask.queueItems(podcastIndex) {
+			plannedTask := &PlannedTask{
+				JobType:    item.task.Kind(),
+				URL:        getTaskURL(item.task),
+				Dest:       item.task.Destination(),
+				Size:       item.task.SizeHint(),
+				SizeSource: SizeSourceFeed,
+			}
+			_, isURLTask := item.task.(*URLDownloadTask)
+			if _, ok := item.task.(*TextSaveTask); ok {
+				plannedTask.SizeSource = SizeSourceText
+			} else if isURLTask && (plannedURLs[plannedTask.URL] || item.task.(*URLDownloadTask).LinkSrc != "") {
+				// A URL shared by several tasks is downloaded once and linked to the other destinations
+				plannedTask.Size = 0
+				plannedTask.SizeSource = SizeSourceDuplicate
+			} else if plannedTask.Size <= 0 && plannedTask.URL != "" {
+				headTasks = append(headTasks, plannedTask)
+			}
+			if isURLTask {
+				plannedURLs[plannedTask.URL] = true
+			}
+			podcastPlan.Tasks = append(podcastPlan.Tasks, plannedTask)
+		}
+		downloadPlan.Podcasts = append(downloadPlan.Podcasts, podcastPlan)
+	}
+	if headRequest {
+		estimateSizesByHead(headTasks, httpClient, threadCount)
+	}
+	for _, podcastPlan := range downloadPlan.Podcasts {
+		for _, plannedTask := range podcastPlan.Tasks {
+			if plannedTask.Size <= 0 && plannedTask.SizeSource != SizeSourceText && plannedTask.SizeSource != SizeSourceDuplicate {
+				plannedTask.SizeSource = SizeSourceUnknown
+				podcastPlan.UnknownSizeCount++
+			}
+			podcastPlan.TotalSize += plannedTask.Size
+		}
+		downloadPlan.TotalTasks += len(podcastPlan.Tasks)
+		downloadPlan.TotalSize += podcastPlan.TotalSize
+		downloadPlan.UnknownSizeCount += podcastPlan.UnknownSizeCount
+	}
+	return downloadPlan
+}
+
+// estimateSizesByHead sets the sizes of specified planned tasks to the Content-Length of HTTP HEAD requests
+// sent in threadCount goroutines
+func estimateSizesByHead(plannedTasks []*PlannedTask, httpClient *http.Client, threadCount int) {
+	if threadCount < 1 {
+		threadCount = 1
+	}
+	taskChan := make(chan *PlannedTask)
+	doneWg := new(sync.WaitGroup)
+	doneWg.Add(threadCount)
+	for i := 0; i < threadCount; i++ {
+		go func() {
+			defer doneWg.Done()
+			for plannedTask := range taskChan {
+				size, err := util.GetRemoteFileSize(httpClient, plannedTask.URL)
+				if err == nil && size > 0 {
+					plannedTask.Size = size
+					plannedTask.SizeSource = SizeSourceHead
+				}
+			}
+		}()
+	}
+	for _, plannedTask := range plannedTasks {
+		taskChan <- plannedTask
+	}
+	close(taskChan)
+	doneWg.Wait()
+}
+
+// formatPlannedSize returns the human readable estimated size, "?" if the size is unknown
+func formatPlannedSize(size int64, sizeSource string) string {
+	if sizeSource == SizeSourceUnknown {
+		return "?"
+	}
+	return util.FormatByteSize(size)
+}
+
+// formatTotalSize returns the human readable total size with the number of tasks whose size is unknown
+func formatTotalSize(totalSize int64, unknownSizeCount int) string {
+	if unknownSizeCount > 0 {
+		return fmt.Sprintf("%s + %d unknown", util.FormatByteSize(totalSize), unknownSizeCount)
+	}
+	return util.FormatByteSize(totalSize)
+}
+
+// WriteTable writes the plan to w as human readable tables, one table for each podcast
+func (p *DownloadPlan) WriteTable(w io.Writer) error {
+	tableWriter := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, podcastPlan := range p.Podcasts {
+		if len(podcastPlan.Tasks) == 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(tableWriter, "%s (%d task(s), %s)\n", podcastPlan.PodcastTitle, len(podcastPlan.Tasks), formatTotalSize(podcastPlan.TotalSize, podcastPlan.UnknownSizeCount))
+		_, _ = fmt.Fprintln(tableWriter, "TYPE\tSIZE\tSOURCE\tDESTINATION")
+		for _, plannedTask := range podcastPlan.Tasks {
+			_, _ = fmt.Fprintf(tableWriter, "%s\t%s\t%s\t%s\n", plannedTask.JobType, formatPlannedSize(plannedTask.Size, plannedTask.SizeSource), plannedTask.SizeSource, plannedTask.Dest)
+		}
+		_, _ = fmt.Fprintln(tableWriter)
+	}
+	_, _ = fmt.Fprintf(tableWriter, "Totally %d task(s) of %d podcast(s), %s\n", p.TotalTasks, len(p.Podcasts), formatTotalSize(p.TotalSize, p.UnknownSizeCount))
+	return tableWriter.Flush()
+}
+
+// WriteJSON writes the plan to w as a JSON document
+func (p *DownloadPlan) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
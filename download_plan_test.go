@@ -0,0 +1,69 @@
+package podownloader
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestPodcastDownloadTasks returns the download tasks of a podcast whose cover has no declared length
+func newTestPodcastDownloadTasks(coverURL string) []*PodcastDownloadTask {
+	return []*PodcastDownloadTask{
+		{
+			PodcastTitle:      "Example",
+			RSSDownloadTask:   &URLDownloadTask{JobType: JobTypeRSS, URL: "http://127.0.0.1:0/rss.xml", Dest: "rss.xml"},
+			CoverDownloadTask: &URLDownloadTask{JobType: JobTypeCover, URL: coverURL, Dest: "cover.jpg"},
+			EpisodeDownloadTasks: []*EpisodeDownloadTask{
+				{
+					ShownotesDownloadTask:  &TextSaveTask{JobType: JobTypeShownotes, Text: "shownotes", Dest: "shownotes.html"},
+					CoverDownloadTask:      &URLDownloadTask{JobType: JobTypeCover, URL: coverURL, Dest: "episode/cover.jpg"},
+					EnclosureDownloadTasks: []*URLDownloadTask{{JobType: JobTypeEnclosure, URL: "http://127.0.0.1:0/1.mp3", Dest: "1.mp3", Length: 2048}},
+				},
+			},
+		},
+	}
+}
+
+func TestNewDownloadPlan(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		http.ServeContent(writer, request, "cover.jpg", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+	downloadPlan := NewDownloadPlan(newTestPodcastDownloadTasks(server.URL), &http.Client{}, 2, true)
+	assert.Equal(t, 5, downloadPlan.TotalTasks)
+	assert.Equal(t, int64(2048+1000+9), downloadPlan.TotalSize)
+	assert.Equal(t, 1, downloadPlan.UnknownSizeCount)
+	tasks := downloadPlan.Podcasts[0].Tasks
+	assert.Equal(t, SizeSourceUnknown, tasks[0].SizeSource)
+	assert.Equal(t, SizeSourceHead, tasks[1].SizeSource)
+	assert.Equal(t, SizeSourceText, tasks[2].SizeSource)
+	assert.Equal(t, SizeSourceDuplicate, tasks[3].SizeSource)
+	assert.Equal(t, SizeSourceFeed, tasks[4].SizeSource)
+
+	var buffer bytes.Buffer
+	assert.Nil(t, downloadPlan.WriteTable(&buffer))
+	assert.True(t, strings.HasPrefix(buffer.String(), "Example (5 task(s), 3.0 KiB + 1 unknown)\n"))
+	assert.True(t, strings.HasSuffix(buffer.String(), "Totally 5 task(s) of 1 podcast(s), 3.0 KiB + 1 unknown\n"))
+}
+
+func TestNewDownloadPlan_WithoutHeadRequest(t *testing.T) {
+	var requestCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&requestCount, 1)
+	}))
+	defer server.Close()
+	downloadPlan := NewDownloadPlan(newTestPodcastDownloadTasks(server.URL), &http.Client{}, 2, false)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requestCount))
+	assert.Equal(t, 5, downloadPlan.TotalTasks)
+	assert.Equal(t, int64(2048+9), downloadPlan.TotalSize)
+	assert.Equal(t, 2, downloadPlan.UnknownSizeCount)
+	tasks := downloadPlan.Podcasts[0].Tasks
+	assert.Equal(t, SizeSourceUnknown, tasks[1].SizeSource)
+	assert.Equal(t, SizeSourceDuplicate, tasks[3].SizeSource)
+}
@@ -10,76 +10,176 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 )
 
-// DownloadQueue is the queue of download tasks
-// include two types of download tasks: URLDownloadTask and TextSaveTask
+// DownloadQueue is the priority queue of download tasks,
+// any type implementing the Task interface can be queued
 type DownloadQueue struct {
-	tasks []interface{}
+	items []*queueItem
 	lock  *sync.Mutex
+	// journal records the task states as the download progresses, nil if no journal is used
+	journal *Journal
 }
 
 // NewDownloadQueueFromDownloadTasks converts []*PodcastDownloadTask to *DownloadQueue
 // and returns the converted *DownloadQueue
-// *DownloadQueue will contain 5 types of download tasks:
+// *DownloadQueue will contain 7 types of download tasks:
 // 1. Podcast cover download task
 // 2. Podcast RSS download task
 // 3. Episode cover download task
 // 4. Episode shownotes download task
 // 5. Episodes enclosures download task
-// All nil tasks will be filtered out
-func NewDownloadQueueFromDownloadTasks(podcastDownloadTasks []*PodcastDownloadTask) *DownloadQueue {
-	var tasks []interface{}
-	for _, podcastDownloadTask := range podcastDownloadTasks {
-		tasks = append(tasks, podcastDownloadTask.RSSDownloadTask)
-		if podcastDownloadTask.CoverDownloadTask != nil {
-			tasks = append(tasks, podcastDownloadTask.CoverDownloadTask)
+// 6. Episode transcripts download task
+// 7. Episode chapters save task
+// All nil tasks will be filtered out, and the tasks will be sorted by specified DownloadOrder.
+// A URL shared by several URLDownloadTask is downloaded only once by the first of them in the download order,
+// the other destinations are hard linked (or copied) from it
+func NewDownloadQueueFromDownloadTasks(podcastDownloadTasks []*PodcastDownloadTask, downloadOrder DownloadOrder) *DownloadQueue {
+	var items []*queueItem
+	for podcastIndex, podcastDownloadTask := range podcastDownloadTasks {
+		items = append(items, podcastDownloadTask.queueItems(podcastIndex)...)
+	}
+	return &DownloadQueue{
+		items: dedupeQueueItems(sortQueueItems(items, downloadOrder)),
+		lock:  &sync.Mutex{},
+	}
+}
+
+// queueItems returns the queue items of all non-nil tasks in PodcastDownloadTask in feed order
+func (p *PodcastDownloadTask) queueItems(podcastIndex int) []*queueItem {
+	var items []*queueItem
+	if p.RSSDownloadTask != nil {
+		items = append(items, &queueItem{task: p.RSSDownloadTask, podcastIndex: podcastIndex})
+	}
+	if p.RSSSaveTask != nil {
+		items = append(items, &queueItem{task: p.RSSSaveTask, podcastIndex: podcastIndex})
+	}
+	if p.CoverDownloadTask != nil {
+		items = append(items, &queueItem{task: p.CoverDownloadTask, podcastIndex: podcastIndex})
+	}
+	for _, episodeDownloadTask := range p.EpisodeDownloadTasks {
+		var episodeTasks []Task
+		if episodeDownloadTask.ShownotesDownloadTask != nil {
+			episodeTasks = append(episodeTasks, episodeDownloadTask.ShownotesDownloadTask)
 		}
-		for _, episodeDownloadTask := range podcastDownloadTask.EpisodeDownloadTasks {
-			if episodeDownloadTask.ShownotesDownloadTask != nil {
-				tasks = append(tasks, episodeDownloadTask.ShownotesDownloadTask)
-			}
-			if episodeDownloadTask.CoverDownloadTask != nil {
-				tasks = append(tasks, episodeDownloadTask.CoverDownloadTask)
+		if episodeDownloadTask.CoverDownloadTask != nil {
+			episodeTasks = append(episodeTasks, episodeDownloadTask.CoverDownloadTask)
+		}
+		for _, enclosureDownloadTask := range episodeDownloadTask.EnclosureDownloadTasks {
+			if enclosureDownloadTask != nil {
+				episodeTasks = append(episodeTasks, enclosureDownloadTask)
 			}
-			for _, enclosureDownloadTask := range episodeDownloadTask.EnclosureDownloadTasks {
-				if enclosureDownloadTask != nil {
-					tasks = append(tasks, enclosureDownloadTask)
-				}
+		}
+		for _, transcriptDownloadTask := range episodeDownloadTask.TranscriptDownloadTasks {
+			if transcriptDownloadTask != nil {
+				episodeTasks = append(episodeTasks, transcriptDownloadTask)
 			}
 		}
+		if episodeDownloadTask.ChapterTask != nil {
+			episodeTasks = append(episodeTasks, episodeDownloadTask.ChapterTask)
+		}
+		for _, task := range episodeTasks {
+			items = append(items, &queueItem{
+				task:         task,
+				podcastIndex: podcastIndex,
+				pubDate:      episodeDownloadTask.PubDate,
+			})
+		}
+	}
+	return items
+}
+
+// NewDownloadQueueFromJournal returns a *DownloadQueue containing the unfinished tasks of specified journal
+// in the recorded queue order, the task states will be recorded to the journal during the download
+func NewDownloadQueueFromJournal(journal *Journal) *DownloadQueue {
+	var items []*queueItem
+	for _, task := range journal.UnfinishedTasks() {
+		items = append(items, &queueItem{task: task, podcastIndex: -1})
 	}
 	return &DownloadQueue{
-		tasks: tasks,
-		lock:  &sync.Mutex{},
+		items:   items,
+		lock:    &sync.Mutex{},
+		journal: journal,
+	}
+}
+
+// CreateJournal writes the queued tasks in queue order to a new journal file at path,
+// the task states will be recorded to the journal during the download
+func (dq *DownloadQueue) CreateJournal(path string) (*Journal, error) {
+	dq.lock.Lock()
+	defer dq.lock.Unlock()
+	tasks := make([]Task, 0, len(dq.items))
+	for _, item := range dq.items {
+		tasks = append(tasks, item.task)
+	}
+	journal, err := CreateJournal(path, tasks)
+	if err != nil {
+		return nil, err
 	}
+	dq.journal = journal
+	return journal, nil
 }
 
-// EnQueue adds an element to the rear of the queue
-func (dq *DownloadQueue) EnQueue(podcastDownloadTasks *PodcastDownloadTask) {
+// EnQueue adds a task to the rear of the queue, it has the lowest priority among the queued tasks
+func (dq *DownloadQueue) EnQueue(task Task) {
 	dq.lock.Lock()
-	dq.tasks = append(dq.tasks, podcastDownloadTasks)
+	dq.items = append(dq.items, &queueItem{task: task, podcastIndex: -1})
 	dq.lock.Unlock()
 }
 
 // DeQueue removes an element from the front of the queue
-func (dq *DownloadQueue) DeQueue() (interface{}, error) {
+func (dq *DownloadQueue) DeQueue() (Task, error) {
 	dq.lock.Lock()
 	defer dq.lock.Unlock()
-	if len(dq.tasks) > 0 {
-		frontDownloadTask := dq.tasks[0]
-		dq.tasks = dq.tasks[1:]
+	if len(dq.items) > 0 {
+		frontDownloadTask := dq.items[0].task
+		dq.items = dq.items[1:]
 		return frontDownloadTask, nil
 	}
 	return nil, errors.New("queue is empty")
 }
 
+// DeQueueAvailable removes and returns the task with the highest priority in the queue whose host is available in hostLimiter,
+// the slot of the host will be acquired for the returned task.
+// When all hosts of the queued tasks are unavailable, a nil task will be returned with the duration to wait
+// before the earliest host becomes available, or 0 if it has to wait for a slot to be released.
+// An error will be returned if the queue is empty
+func (dq *DownloadQueue) DeQueueAvailable(hostLimiter *HostLimiter) (Task, time.Duration, error) {
+	dq.lock.Lock()
+	defer dq.lock.Unlock()
+	if len(dq.items) == 0 {
+		return nil, 0, errors.New("queue is empty")
+	}
+	var (
+		now              = time.Now()
+		minWait          time.Duration
+		unavailableHosts = make(map[string]bool)
+	)
+	for index, item := range dq.items {
+		host := getTaskHost(item.task)
+		if unavailableHosts[host] {
+			continue
+		}
+		ok, wait := hostLimiter.TryAcquire(host, now)
+		if ok {
+			dq.items = append(dq.items[:index], dq.items[index+1:]...)
+			return item.task, 0, nil
+		}
+		unavailableHosts[host] = true
+		if wait > 0 && (minWait == 0 || wait < minWait) {
+			minWait = wait
+		}
+	}
+	return nil, minWait, nil
+}
+
 // Front returns queue front
-func (dq *DownloadQueue) Front() (interface{}, error) {
+func (dq *DownloadQueue) Front() (Task, error) {
 	dq.lock.Lock()
 	defer dq.lock.Unlock()
-	if len(dq.tasks) > 0 {
-		return dq.tasks[0], nil
+	if len(dq.items) > 0 {
+		return dq.items[0].task, nil
 	}
 	return nil, errors.New("queue is empty")
 }
@@ -88,19 +188,33 @@ func (dq *DownloadQueue) Front() (interface{}, error) {
 func (dq *DownloadQueue) Length() int {
 	dq.lock.Lock()
 	defer dq.lock.Unlock()
-	return len(dq.tasks)
+	return len(dq.items)
 }
 
 // IsEmpty returns whether the queue is empty
 func (dq *DownloadQueue) IsEmpty() bool {
 	dq.lock.Lock()
 	defer dq.lock.Unlock()
-	return len(dq.tasks) == 0
+	return len(dq.items) == 0
 }
 
 // StartDownload will start threadCount download goroutines to download podcasts
-// and returns the destination download paths of the failed tasks
-func (dq *DownloadQueue) StartDownload(threadCount int, httpClient *http.Client, logger *logger.Logger) []string {
+// and returns the download result which contains the failed tasks with the reasons why they failed.
+// Download tasks are started in queue order, except that tasks whose host is limited by
+// DownloadOptions.MaxTasksPerHost or DownloadOptions.HostInterval are skipped until the host becomes available.
+// The first SIGINT or SIGTERM signal stops starting new tasks and waits for the in-flight tasks done,
+// the second signal aborts the in-flight tasks by cancelling their requests
+func (dq *DownloadQueue) StartDownload(threadCount int, httpClient *http.Client, logger *logger.Logger, options *DownloadOptions) *DownloadResult {
+	termChan := make(chan os.Signal, 1)
+	signal.Notify(termChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(termChan)
+	return dq.StartDownloadWithSignals(threadCount, httpClient, logger, options, termChan)
+}
+
+// StartDownloadWithSignals is StartDownload with the cancellation signals received from specified channel
+// instead of SIGINT and SIGTERM: the first signal stops starting new tasks and waits for the in-flight tasks done,
+// the second signal aborts the in-flight tasks
+func (dq *DownloadQueue) StartDownloadWithSignals(threadCount int, httpClient *http.Client, logger *logger.Logger, options *DownloadOptions, signals <-chan os.Signal) *DownloadResult {
 	realThreadCount := threadCount
 	// When specified download threads is greater than the number of download tasks,
 	// using the number of download tasks as download threads
@@ -111,51 +225,111 @@ func (dq *DownloadQueue) StartDownload(threadCount int, httpClient *http.Client,
 	// Using doneWg to wait for all download workers done
 	doneWg := new(sync.WaitGroup)
 	doneWg.Add(realThreadCount)
-	progressBar := mpb.New(
+	progressBar := NewProgress(options.ProgressMode,
 		mpb.WithWaitGroup(doneWg),
 	)
-	ctx, cancelFunc := context.WithCancel(context.Background())
-	downloadWorker := NewDownloadWorker(doneWg, httpClient, progressBar, logger, realThreadCount)
+	// drainCtx is cancelled by the first cancellation signal or low disk space to stop starting new tasks,
+	// abortCtx is cancelled by the second cancellation signal to abort in-flight tasks
+	drainCtx, drainCancelFunc := context.WithCancel(context.Background())
+	abortCtx, abortCancelFunc := context.WithCancel(context.Background())
+	defer drainCancelFunc()
+	defer abortCancelFunc()
+	hostLimiter := NewHostLimiter(options.MaxTasksPerHost, options.HostInterval)
+	// Segmented downloads can use the slots of the threads that are not started because there are fewer tasks
+	threadBudget := NewThreadBudget(threadCount)
+	downloadWorker := NewDownloadWorker(doneWg, httpClient, progressBar, logger, hostLimiter, threadBudget, dq.journal, options, drainCancelFunc, realThreadCount)
+	var unstartedTasks []Task
 
 	// Start all download workers
 	for i := 0; i < realThreadCount; i++ {
-		go downloadWorker.WorkerFunc()
+		go downloadWorker.WorkerFunc(drainCtx, abortCtx)
 	}
 
-	// Listen to the SIGINT and SIGTERM signal
+	// Listen to the cancellation signals
+	finished := make(chan struct{})
+	defer close(finished)
 	go func() {
-		termChan := make(chan os.Signal)
-		signal.Notify(termChan, syscall.SIGINT, syscall.SIGTERM)
-
-		<-termChan
+		select {
+		case <-signals:
+		case <-finished:
+			return
+		}
+		logger.Println("Received cancellation signal, waiting for in-flight download tasks done, send the signal again to abort them")
+		drainCancelFunc()
 
-		logger.Println("Received cancellation signal, waiting for all download tasks done")
-		cancelFunc()
+		select {
+		case <-signals:
+		case <-finished:
+			return
+		}
+		logger.Println("Received cancellation signal again, aborting in-flight download tasks")
+		abortCancelFunc()
 	}()
 
-	// Producer: feed download tasks to downloadWorker.IngestChan
+	// Producer: feed download tasks to downloadWorker.TasksChan
 	go func() {
+		defer close(downloadWorker.TasksChan)
 		for {
 			select {
-			case <-ctx.Done():
+			case <-drainCtx.Done():
 				// Remove all unstarted download tasks
 				for len(downloadWorker.TasksChan) > 0 {
-					<-downloadWorker.TasksChan
+					task := <-downloadWorker.TasksChan
+					hostLimiter.Release(getTaskHost(task))
+					unstartedTasks = append(unstartedTasks, task)
+				}
+				for !dq.IsEmpty() {
+					task, _ := dq.DeQueue()
+					unstartedTasks = append(unstartedTasks, task)
 				}
-				close(downloadWorker.TasksChan)
 				return
 			default:
-				task, err := dq.DeQueue()
+				task, wait, err := dq.DeQueueAvailable(hostLimiter)
 				if err != nil {
-					close(downloadWorker.TasksChan)
 					return
 				}
-				downloadWorker.TasksChan <- task
+				if task == nil {
+					waitForAvailableHost(drainCtx, hostLimiter, wait)
+					continue
+				}
+				select {
+				case downloadWorker.TasksChan <- task:
+				case <-drainCtx.Done():
+					hostLimiter.Release(getTaskHost(task))
+					unstartedTasks = append(unstartedTasks, task)
+				}
 			}
 		}
 	}()
 
 	// Wait for all download workers done
 	progressBar.Wait()
-	return downloadWorker.FailedTaskDestPaths
+	unstartedTasks = append(unstartedTasks, downloadWorker.UnstartedTasks...)
+	taskResults := downloadWorker.TaskResults
+	for _, task := range unstartedTasks {
+		taskResults = append(taskResults, newTaskResult(task, TaskResultUnstarted, nil, 0, 0, nil))
+	}
+	return &DownloadResult{
+		TaskResults:      taskResults,
+		FailedTasks:      downloadWorker.FailedTasks,
+		InterruptedTasks: downloadWorker.InterruptedTasks,
+		UnstartedTasks:   unstartedTasks,
+		LowDiskSpace:     downloadWorker.IsLowDiskSpace(),
+	}
+}
+
+// waitForAvailableHost blocks until a host slot is released, the specified wait duration (if greater than 0)
+// has passed or the context is done
+func waitForAvailableHost(ctx context.Context, hostLimiter *HostLimiter, wait time.Duration) {
+	var timerChan <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timerChan = timer.C
+	}
+	select {
+	case <-ctx.Done():
+	case <-hostLimiter.Released():
+	case <-timerChan:
+	}
 }
@@ -0,0 +1,171 @@
+package podownloader
+
+import (
+	"PoDownloader/logger"
+	"PoDownloader/util"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeTask is a Task used to test that any Task implementation can be queued
+type fakeTask struct {
+	dest     string
+	err      error
+	executed *int32
+}
+
+func (f *fakeTask) Execute(_ context.Context, _ *TaskEnv) error {
+	atomic.AddInt32(f.executed, 1)
+	return f.err
+}
+
+func (f *fakeTask) Destination() string { return f.dest }
+
+func (f *fakeTask) Kind() string { return "Fake" }
+
+func (f *fakeTask) DisplayName() string { return f.dest }
+
+func (f *fakeTask) SizeHint() int64 { return 0 }
+
+func TestDownloadQueue_StartDownload(t *testing.T) {
+	var executed int32
+	downloadQueue := &DownloadQueue{lock: &sync.Mutex{}}
+	downloadQueue.EnQueue(&fakeTask{dest: "a", executed: &executed})
+	downloadQueue.EnQueue(&fakeTask{dest: "b", executed: &executed, err: errors.New("foobar")})
+	downloadQueue.EnQueue(&fakeTask{dest: "c", executed: &executed})
+	assert.Equal(t, 3, downloadQueue.Length())
+
+	testLogger, _ := logger.NewLogger("")
+	downloadResult := downloadQueue.StartDownload(2, &http.Client{}, testLogger, DefaultDownloadOptions())
+	assert.Equal(t, int32(3), executed)
+	assert.Equal(t, 1, len(downloadResult.FailedTasks))
+	assert.Equal(t, "b", downloadResult.FailedTasks[0].Dest)
+	assert.Equal(t, "Fake", downloadResult.FailedTasks[0].JobType)
+	assert.Equal(t, TaskResultFailed, downloadResult.FailedTasks[0].Status)
+	assert.Equal(t, 3, len(downloadResult.TaskResults))
+	assert.False(t, downloadResult.IsInterrupted())
+	assert.True(t, downloadQueue.IsEmpty())
+}
+
+// blockingTask is a Task that signals started when it is executed and blocks until release is closed
+type blockingTask struct {
+	fakeTask
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingTask) Execute(ctx context.Context, env *TaskEnv) error {
+	close(b.started)
+	<-b.release
+	return b.fakeTask.Execute(ctx, env)
+}
+
+func TestDownloadQueue_StartDownloadWithSignals_Drain(t *testing.T) {
+	var executed int32
+	first := &blockingTask{fakeTask: fakeTask{dest: "a", executed: &executed}, started: make(chan struct{}), release: make(chan struct{})}
+	downloadQueue := &DownloadQueue{lock: &sync.Mutex{}}
+	downloadQueue.EnQueue(first)
+	downloadQueue.EnQueue(&fakeTask{dest: "b", executed: &executed})
+	downloadQueue.EnQueue(&fakeTask{dest: "c", executed: &executed})
+
+	signals := make(chan os.Signal, 2)
+	go func() {
+		<-first.started
+		signals <- syscall.SIGINT
+		// The in-flight task is not aborted by the first signal
+		time.Sleep(50 * time.Millisecond)
+		close(first.release)
+	}()
+	testLogger, _ := logger.NewLogger("")
+	downloadResult := downloadQueue.StartDownloadWithSignals(1, &http.Client{}, testLogger, DefaultDownloadOptions(), signals)
+	assert.Equal(t, int32(1), executed)
+	assert.Empty(t, downloadResult.FailedTasks)
+	assert.Empty(t, downloadResult.InterruptedTasks)
+	assert.Equal(t, 2, len(downloadResult.UnstartedTasks))
+	statuses := make(map[string]TaskResultStatus)
+	for _, taskResult := range downloadResult.TaskResults {
+		statuses[taskResult.Dest] = taskResult.Status
+	}
+	assert.Equal(t, map[string]TaskResultStatus{"a": TaskResultDone, "b": TaskResultUnstarted, "c": TaskResultUnstarted}, statuses)
+}
+
+func TestDownloadQueue_StartDownloadWithSignals_Abort(t *testing.T) {
+	flushed := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("ETag", "\"v1\"")
+		writer.Header().Set("Content-Length", strconv.Itoa(1024*1024))
+		_, _ = writer.Write(make([]byte, 1024))
+		writer.(http.Flusher).Flush()
+		close(flushed)
+		// The rest of the content is never sent, the download is in flight until it is aborted
+		<-request.Context().Done()
+	}))
+	defer server.Close()
+	task := &URLDownloadTask{JobType: JobTypeEnclosure, URL: server.URL, Dest: filepath.Join(t.TempDir(), "episode.mp3")}
+	downloadQueue := &DownloadQueue{lock: &sync.Mutex{}}
+	downloadQueue.EnQueue(task)
+
+	signals := make(chan os.Signal, 2)
+	go func() {
+		<-flushed
+		for i := 0; i < 100; i++ {
+			if size, err := util.GetFileSize(task.PartialFilePath()); err == nil && size > 0 {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		signals <- syscall.SIGINT
+		signals <- syscall.SIGINT
+	}()
+	options := DefaultDownloadOptions()
+	options.ProgressMode = ProgressModePlain
+	testLogger, _ := logger.NewLogger("")
+	downloadResult := downloadQueue.StartDownloadWithSignals(1, &http.Client{}, testLogger, options, signals)
+	assert.Equal(t, 1, len(downloadResult.InterruptedTasks))
+	assert.Equal(t, TaskResultInterrupted, downloadResult.TaskResults[0].Status)
+	assert.Empty(t, downloadResult.FailedTasks)
+	// The partial file is kept to be resumed on the next run
+	assert.False(t, util.IsPathExist(task.Dest))
+	size, err := util.GetFileSize(task.PartialFilePath())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1024), size)
+	assert.True(t, util.IsPathExist(task.partialMetaFilePath()))
+}
+
+func TestDownloadQueue_StartDownloadWithSignals_DrainRetry(t *testing.T) {
+	var executed int32
+	downloadQueue := &DownloadQueue{lock: &sync.Mutex{}}
+	downloadQueue.EnQueue(&fakeTask{dest: "a", executed: &executed, err: &HTTPStatusError{StatusCode: http.StatusServiceUnavailable}})
+
+	signals := make(chan os.Signal, 2)
+	go func() {
+		for atomic.LoadInt32(&executed) == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		signals <- syscall.SIGINT
+	}()
+	options := DefaultDownloadOptions()
+	options.RetryPolicy = &RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Minute}
+	sink := &recordingEventSink{}
+	options.Events = sink
+	testLogger, _ := logger.NewLogger("")
+	downloadResult := downloadQueue.StartDownloadWithSignals(1, &http.Client{}, testLogger, options, signals)
+	// The task waiting to be retried is reported as unstarted rather than failed
+	assert.Equal(t, int32(1), executed)
+	assert.Empty(t, downloadResult.FailedTasks)
+	assert.Equal(t, 1, len(downloadResult.UnstartedTasks))
+	assert.Equal(t, 1, len(downloadResult.TaskResults))
+	assert.Equal(t, TaskResultUnstarted, downloadResult.TaskResults[0].Status)
+	assert.Equal(t, "unstarted", sink.events[len(sink.events)-1].Status)
+}
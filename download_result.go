@@ -0,0 +1,84 @@
+package podownloader
+
+import (
+	"errors"
+	"time"
+)
+
+// TaskResultStatus is the final status of a download task in a run
+type TaskResultStatus string
+
+const (
+	// TaskResultDone means the task has been completed successfully
+	TaskResultDone TaskResultStatus = "done"
+	// TaskResultFailed means the task has failed after all attempts
+	TaskResultFailed TaskResultStatus = "failed"
+	// TaskResultInterrupted means the in-flight task was aborted by a second cancellation signal or low disk space
+	TaskResultInterrupted TaskResultStatus = "interrupted"
+	// TaskResultUnstarted means the task was not started because of a cancellation signal or low disk space
+	TaskResultUnstarted TaskResultStatus = "unstarted"
+)
+
+// TaskResult records the result of a download task, including the reason why it failed
+type TaskResult struct {
+	JobType string
+	// Title is the display name of the task
+	Title  string
+	URL    string
+	Dest   string
+	Status TaskResultStatus
+	// Bytes is the number of bytes transferred by all attempts
+	Bytes    int64
+	Duration time.Duration
+	Attempts int
+	// HTTPStatus is the status code of the last HTTP response, 0 if no response was received
+	HTTPStatus int
+	Err        error
+}
+
+// newTaskResult returns a TaskResult instance of specified task with the statistics collected in stats
+func newTaskResult(task Task, status TaskResultStatus, stats *TaskStats, duration time.Duration, attempts int, err error) *TaskResult {
+	taskResult := &TaskResult{
+		JobType:    task.Kind(),
+		Title:      task.DisplayName(),
+		URL:        getTaskURL(task),
+		Dest:       task.Destination(),
+		Status:     status,
+		Bytes:      stats.Bytes(),
+		Duration:   duration,
+		Attempts:   attempts,
+		HTTPStatus: stats.HTTPStatus(),
+		Err:        err,
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		taskResult.HTTPStatus = statusErr.StatusCode
+	}
+	return taskResult
+}
+
+// ErrorClass returns the class of TaskResult.Err, empty if the task did not fail
+func (r *TaskResult) ErrorClass() ErrorClass {
+	return ClassifyError(r.Err)
+}
+
+// DownloadResult is the result of DownloadQueue.StartDownload
+type DownloadResult struct {
+	// TaskResults are the results of all tasks in the order they finished, unstarted tasks are at the end
+	TaskResults []*TaskResult
+	// FailedTasks are the tasks that failed with the reasons why they failed
+	FailedTasks []*TaskResult
+	// InterruptedTasks are the in-flight tasks that were aborted by a second cancellation signal or low disk space,
+	// the partial files of interrupted downloads are kept so that they can be resumed
+	InterruptedTasks []*TaskResult
+	// UnstartedTasks are the tasks that were not started because of a cancellation signal or low disk space
+	UnstartedTasks []Task
+	// LowDiskSpace is true if no new tasks were started because the free disk space dropped below
+	// DownloadOptions.MinFreeSpace
+	LowDiskSpace bool
+}
+
+// IsInterrupted returns whether the download was interrupted by a cancellation signal or low disk space
+func (r *DownloadResult) IsInterrupted() bool {
+	return len(r.InterruptedTasks) > 0 || len(r.UnstartedTasks) > 0
+}
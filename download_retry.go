@@ -0,0 +1,82 @@
+package podownloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how failed download tasks are retried
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts of a download task, including the first attempt
+	MaxAttempts int
+	// BaseBackoff is the delay before the first retry, the delay doubles after each retry
+	BaseBackoff time.Duration
+	// MaxBackoff is the upper limit of the delay between two attempts
+	MaxBackoff time.Duration
+	// Jitter is the fraction of the delay that is randomized, between 0 and 1
+	Jitter float64
+}
+
+// DefaultRetryPolicy returns the default retry policy
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		BaseBackoff: time.Second,
+		MaxBackoff:  30 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+// ShouldRetry returns whether a task that failed with specified error on specified attempt should be retried
+func (p *RetryPolicy) ShouldRetry(attempt int, err error) bool {
+	return attempt < p.MaxAttempts && IsRetryableError(err)
+}
+
+// Backoff returns the delay before the next attempt of a task that failed with specified error on specified attempt.
+// The Retry-After response header will be honored if the error is an HTTPStatusError that carries it,
+// the delay never exceeds RetryPolicy.MaxBackoff
+func (p *RetryPolicy) Backoff(attempt int, err error) time.Duration {
+	var (
+		statusErr *HTTPStatusError
+		backoff   float64
+	)
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		backoff = float64(statusErr.RetryAfter)
+	} else {
+		backoff = float64(p.BaseBackoff) * math.Pow(2, float64(attempt-1))
+		if p.Jitter > 0 {
+			backoff = backoff * (1 - p.Jitter + rand.Float64()*2*p.Jitter)
+		}
+	}
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	return time.Duration(backoff)
+}
+
+// IsRetryableError returns whether a download task failed with specified error is worth retrying:
+// timeouts, connection resets, unexpected EOF, 5xx responses and 429 responses are retryable
+func IsRetryableError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode == http.StatusRequestTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,47 @@
+package podownloader
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	assert.True(t, IsRetryableError(&HTTPStatusError{StatusCode: http.StatusServiceUnavailable}))
+	assert.True(t, IsRetryableError(&HTTPStatusError{StatusCode: http.StatusTooManyRequests}))
+	assert.False(t, IsRetryableError(&HTTPStatusError{StatusCode: http.StatusNotFound}))
+	assert.True(t, IsRetryableError(fmt.Errorf("read: %w", syscall.ECONNRESET)))
+	assert.True(t, IsRetryableError(io.ErrUnexpectedEOF))
+	assert.False(t, IsRetryableError(&ContentTypeError{}))
+	assert.False(t, IsRetryableError(errors.New("foobar")))
+	assert.False(t, IsRetryableError(nil))
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	retryPolicy := &RetryPolicy{MaxAttempts: 5, BaseBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	assert.Equal(t, time.Second, retryPolicy.Backoff(1, io.ErrUnexpectedEOF))
+	assert.Equal(t, 2*time.Second, retryPolicy.Backoff(2, io.ErrUnexpectedEOF))
+	assert.Equal(t, 5*time.Second, retryPolicy.Backoff(4, io.ErrUnexpectedEOF))
+	assert.Equal(t, 3*time.Second, retryPolicy.Backoff(1, &HTTPStatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 3 * time.Second}))
+	assert.Equal(t, 5*time.Second, retryPolicy.Backoff(1, &HTTPStatusError{StatusCode: http.StatusServiceUnavailable, RetryAfter: 24 * time.Hour}))
+
+	retryPolicy.Jitter = 0.5
+	backoff := retryPolicy.Backoff(1, io.ErrUnexpectedEOF)
+	assert.GreaterOrEqual(t, backoff, 500*time.Millisecond)
+	assert.LessOrEqual(t, backoff, 1500*time.Millisecond)
+	for i := 0; i < 100; i++ {
+		assert.LessOrEqual(t, retryPolicy.Backoff(4, io.ErrUnexpectedEOF), 5*time.Second)
+	}
+}
+
+func TestRetryPolicy_ShouldRetry(t *testing.T) {
+	retryPolicy := &RetryPolicy{MaxAttempts: 2}
+	assert.True(t, retryPolicy.ShouldRetry(1, io.ErrUnexpectedEOF))
+	assert.False(t, retryPolicy.ShouldRetry(2, io.ErrUnexpectedEOF))
+	assert.False(t, retryPolicy.ShouldRetry(1, &HTTPStatusError{StatusCode: http.StatusForbidden}))
+}
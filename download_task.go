@@ -2,6 +2,7 @@ package podownloader
 
 import (
 	"PoDownloader/util"
+	"context"
 	"fmt"
 	"github.com/vbauerster/mpb/v8"
 	"github.com/vbauerster/mpb/v8/decor"
@@ -9,14 +10,40 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 )
 
+// Job types of download tasks
+const (
+	JobTypeRSS        = "RSS"
+	JobTypeCover      = "Cover"
+	JobTypeEnclosure  = "Enclosure"
+	JobTypeShownotes  = "Shownotes"
+	JobTypeTranscript = "Transcript"
+)
+
+// expectedContentTypeFamilies maps the job type of a URLDownloadTask to the Content-Type families
+// that are accepted when DownloadOptions.CheckContentType is enabled
+var expectedContentTypeFamilies = map[string][]string{
+	JobTypeRSS:       {"xml"},
+	JobTypeCover:     {"image"},
+	JobTypeEnclosure: {"audio", "video"},
+}
+
 // URLDownloadTask is a download task that download a file from URL to Dest
 type URLDownloadTask struct {
 	JobName string `json:"jobName,omitempty"`
 	JobType string `json:"jobType,omitempty"`
 	URL     string `json:"url,omitempty"`
 	Dest    string `json:"dest,omitempty"`
+	// Length is the file size in bytes declared in the feed, 0 if unknown
+	Length int64 `json:"length,omitempty"`
+	// LinkDests are the destinations of the duplicate tasks with the same URL,
+	// they are hard linked (or copied) from Dest after the download is completed
+	LinkDests []string `json:"linkDests,omitempty"`
+	// LinkSrc is the destination of an earlier task with the same URL that has already been downloaded,
+	// Dest is hard linked (or copied) from it instead of downloading the URL again if it exists
+	LinkSrc string `json:"linkSrc,omitempty"`
 }
 
 // TextSaveTask is a file save task that save the Text to Dest
@@ -30,125 +57,399 @@ type TextSaveTask struct {
 // EpisodeDownloadTask contains all download tasks in an episode
 type EpisodeDownloadTask struct {
 	EpisodeTitle           string             `json:"episodeTitle,omitempty"`
+	PubDate                *time.Time         `json:"pubDate,omitempty"`
 	BaseDestDir            string             `json:"baseDestDir,omitempty"`
 	EnclosureDownloadTasks []*URLDownloadTask `json:"enclosureDownloadTasks,omitempty"`
 	CoverDownloadTask      *URLDownloadTask   `json:"coverDownloadTask,omitempty"`
 	ShownotesDownloadTask  *TextSaveTask      `json:"shownotesDownloadTask,omitempty"`
+	// TranscriptDownloadTasks download the transcripts declared by podcast:transcript
+	TranscriptDownloadTasks []*URLDownloadTask `json:"transcriptDownloadTasks,omitempty"`
+	// ChapterTask saves the chapters of the episode as chapters.json, nil if the episode has no chapters
+	ChapterTask *ChapterTask `json:"chapterTask,omitempty"`
 }
 
 // PodcastDownloadTask contains all download tasks in a podcast
 type PodcastDownloadTask struct {
+	// RSS is the feed URL of the podcast
+	RSS                  string                 `json:"rss,omitempty"`
 	PodcastTitle         string                 `json:"podcastTitle,omitempty"`
 	BaseDestDir          string                 `json:"baseDestDir,omitempty"`
 	EpisodeDownloadTasks []*EpisodeDownloadTask `json:"episodeDownloadTasks,omitempty"`
 	CoverDownloadTask    *URLDownloadTask       `json:"coverDownloadTask,omitempty"`
 	RSSDownloadTask      *URLDownloadTask       `json:"rssDownloadTask,omitempty"`
+	// RSSSaveTask saves the already fetched feed content, it is used instead of RSSDownloadTask
+	// so that the feed is not downloaded twice
+	RSSSaveTask *TextSaveTask `json:"rssSaveTask,omitempty"`
+}
+
+// progressBarTaskName returns the task name displayed in front of a download progress bar,
+// retries are marked with the attempt number
+func progressBarTaskName(jobType string, attempt int) string {
+	if attempt > 1 {
+		return fmt.Sprintf("[Retry #%d|%s]", attempt, util.FillTextToLength(jobType, 9))
+	}
+	return fmt.Sprintf("[Download|%s]", util.FillTextToLength(jobType, 9))
+}
+
+// Execute implements the Task interface, it writes TextSaveTask.Text to TextSaveTask.Dest
+func (t *TextSaveTask) Execute(_ context.Context, env *TaskEnv) error {
+	var err error
+	if env.ProgressBar == nil {
+		err = t.Save()
+	} else {
+		err = t.SaveWithProgress(env.ProgressBar)
+	}
+	if err == nil {
+		env.Stats.AddBytes(t.SizeHint())
+	}
+	return err
+}
+
+// Destination implements the Task interface
+func (t *TextSaveTask) Destination() string {
+	return t.Dest
+}
+
+// Kind implements the Task interface
+func (t *TextSaveTask) Kind() string {
+	return t.JobType
+}
+
+// DisplayName implements the Task interface
+func (t *TextSaveTask) DisplayName() string {
+	return t.JobName
+}
+
+// SizeHint implements the Task interface, returns the size of TextSaveTask.Text
+func (t *TextSaveTask) SizeHint() int64 {
+	return int64(len(t.Text))
 }
 
-// Save writes TextSaveTask.Text to TextSaveTask.Dest
+// OutputFiles implements the OutputTask interface
+func (t *TextSaveTask) OutputFiles() []string {
+	return []string{t.Dest}
+}
+
+// Save writes TextSaveTask.Text to TextSaveTask.Dest atomically
 func (t *TextSaveTask) Save() error {
-	return util.WriteContentToFile(t.Text, t.Dest)
+	err := util.EnsureDirAll(filepath.Dir(t.Dest))
+	if err != nil {
+		return err
+	}
+	return util.WriteContentToFileAtomically(t.Text, t.Dest)
 }
 
-// SaveWithProgress writes TextSaveTask.Text to TextSaveTask.Dest with progress bar
+// SaveWithProgress writes TextSaveTask.Text to TextSaveTask.Dest atomically with progress bar
 func (t *TextSaveTask) SaveWithProgress(progressBar *mpb.Progress) error {
 	destBaseDir := filepath.Dir(t.Dest)
 	err := util.EnsureDirAll(destBaseDir)
 	if err != nil {
 		return err
 	}
-	taskName := fmt.Sprintf("[Download|%s]", util.FillTextToLength(t.JobType, 9))
+	taskName := progressBarTaskName(t.JobType, 1)
 	bar := progressBar.AddBar(
 		1,
 		mpb.PrependDecorators(
 			decor.Name(taskName, decor.WC{W: len(taskName) + 1, C: decor.DidentRight}),
-			decor.Name(util.GetFirstNCharacters(t.JobName, 20), decor.WCSyncSpaceR),
+			decor.Name(util.GetFirstNCharacters(t.DisplayName(), 20), decor.WCSyncSpaceR),
 		),
 		mpb.AppendDecorators(
 			decor.Percentage(decor.WC{W: 5}),
 		),
 	)
-	err = util.WriteContentToFile(t.Text, t.Dest)
+	err = util.WriteContentToFileAtomically(t.Text, t.Dest)
 	if err != nil {
+		bar.Abort(false)
 		return err
 	}
 	bar.IncrBy(1)
 	return nil
 }
 
-// IsDestFileExist returns whether the TextSaveTask destination file exists
+// IsDestFileExist returns whether the TextSaveTask destination file exists,
+// the destination file only exists after the text has been completely written
 func (t *TextSaveTask) IsDestFileExist() bool {
-	return util.IsPathExist(t.Dest)
+	return util.IsFileExist(t.Dest)
 }
 
-// Download downloads URLDownloadTask.URL to URLDownloadTask.Dest
-func (c *URLDownloadTask) Download(httpClient *http.Client) error {
-	resp, err := httpClient.Get(c.URL)
-	if err != nil {
-		return err
+// Execute implements the Task interface, it downloads URLDownloadTask.URL to URLDownloadTask.Dest
+// and links the downloaded file to URLDownloadTask.LinkDests
+func (c *URLDownloadTask) Execute(ctx context.Context, env *TaskEnv) error {
+	var err error
+	if c.LinkSrc != "" && util.IsFileExist(c.LinkSrc) {
+		err = c.linkFromSource()
+	} else {
+		err = c.download(ctx, env)
 	}
-	defer resp.Body.Close()
-	out, err := os.Create(c.Dest)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
-	_, err = io.Copy(out, resp.Body)
-	return err
+	return c.linkDuplicates()
+}
+
+// Destination implements the Task interface
+func (c *URLDownloadTask) Destination() string {
+	return c.Dest
+}
+
+// Kind implements the Task interface
+func (c *URLDownloadTask) Kind() string {
+	return c.JobType
+}
+
+// DisplayName implements the Task interface
+func (c *URLDownloadTask) DisplayName() string {
+	return c.JobName
+}
+
+// SizeHint implements the Task interface, returns the file size declared in the feed
+func (c *URLDownloadTask) SizeHint() int64 {
+	return c.Length
+}
+
+// SourceURL implements the RemoteTask interface
+func (c *URLDownloadTask) SourceURL() string {
+	return c.URL
+}
+
+// OutputFiles implements the OutputTask interface, returns the destination and the linked destinations
+func (c *URLDownloadTask) OutputFiles() []string {
+	return append([]string{c.Dest}, c.LinkDests...)
+}
+
+// Download downloads URLDownloadTask.URL to URLDownloadTask.Dest
+func (c *URLDownloadTask) Download(httpClient *http.Client, options *DownloadOptions) error {
+	return c.download(context.Background(), &TaskEnv{HTTPClient: httpClient, Options: options, Attempt: 1})
 }
 
 // DownloadWithProgress downloads URLDownloadTask.URL to URLDownloadTask.Dest with progress bar
-func (c *URLDownloadTask) DownloadWithProgress(httpClient *http.Client, progressBar *mpb.Progress) error {
+func (c *URLDownloadTask) DownloadWithProgress(httpClient *http.Client, progressBar *mpb.Progress, options *DownloadOptions) error {
+	return c.download(context.Background(), &TaskEnv{HTTPClient: httpClient, ProgressBar: progressBar, Options: options, Attempt: 1})
+}
+
+// download downloads URLDownloadTask.URL to the partial file and renames the partial file
+// to URLDownloadTask.Dest after the download is completed.
+// If a resumable partial file exists, the download will continue from the end of the partial file.
+// Large enclosures are downloaded in segments when the server supports range requests and there are idle thread slots.
+// No progress bar will be displayed if env.ProgressBar is nil, env.Attempt is displayed in the progress bar when retrying
+func (c *URLDownloadTask) download(ctx context.Context, env *TaskEnv) error {
 	destBaseDir := filepath.Dir(c.Dest)
 	err := util.EnsureDirAll(destBaseDir)
 	if err != nil {
 		return err
 	}
-	resp, err := httpClient.Get(c.URL)
+	if meta := c.segmentedPartialMeta(); meta != nil {
+		err = c.resumeSegments(ctx, env, meta)
+		if err != errSegmentsNotResumable {
+			return err
+		}
+		// The segments can not be resumed, restart the download from scratch
+		c.removePartialFiles()
+	}
+	resp, offset, err := c.openDownloadResponse(ctx, env.HTTPClient)
 	if err != nil {
 		return err
 	}
+	// The partial file already contains the complete content
+	if resp == nil {
+		return c.finalizePartialFile()
+	}
 	defer resp.Body.Close()
-	out, err := os.Create(c.Dest)
+	env.Stats.SetHTTPStatus(resp.StatusCode)
+	if env.Options.CheckContentType {
+		err = c.checkContentType(resp)
+		if err != nil {
+			return err
+		}
+	}
+	if offset == 0 {
+		if segments := c.planSegments(env, resp); segments != nil {
+			return c.startSegments(ctx, env, resp, segments)
+		}
+	}
+	fileFlag := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if offset == 0 {
+		fileFlag = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		err = newPartialDownloadMeta(c.URL, resp).save(c.partialMetaFilePath())
+		if err != nil {
+			return err
+		}
+	}
+	out, err := os.OpenFile(c.PartialFilePath(), fileFlag, 0644)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
-	taskName := fmt.Sprintf("[Download|%s]", util.FillTextToLength(c.JobType, 9))
+	var (
+		body io.Reader = util.NewRateLimitedReader(ctx, &statsReader{reader: resp.Body, stats: env.Stats}, env.Options.rateLimiters()...)
+		bar  *mpb.Bar
+	)
+	if env.ProgressBar != nil {
+		bar = c.addProgressBar(env.ProgressBar, resp.ContentLength, offset, env.Attempt)
+		// Wrap the rate limited reader so that the speed decorator reflects the throttled speed
+		body = bar.ProxyReader(body)
+	}
+	_, err = io.Copy(&diskSpaceWriter{writer: out, guard: newDiskSpaceGuard(c.PartialFilePath(), env.Options.MinFreeSpace)}, body)
+	if err == nil {
+		// Make sure the content is flushed to disk before the partial file is renamed
+		err = out.Sync()
+	}
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if bar != nil {
+			bar.Abort(false)
+		}
+		return err
+	}
+	if bar != nil {
+		// Mark the bar as completed, the response may not contain Content-Length
+		bar.SetTotal(-1, true)
+	}
+	return c.finalizePartialFile()
+}
+
+// openDownloadResponse sends the download request and returns the response with the offset
+// that the response body starts from.
+// When there is a resumable partial file, a Range request with If-Range header will be sent,
+// and the download will be restarted from scratch if the server ignores the Range header
+// or the validator has changed.
+// The returned response will be nil if the partial file already contains the complete content
+func (c *URLDownloadTask) openDownloadResponse(ctx context.Context, httpClient *http.Client) (*http.Response, int64, error) {
+	offset, meta := c.resumeOffset()
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			if validator := meta.ifRangeValidator(); validator != "" {
+				req.Header.Set("If-Range", validator)
+			}
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, 0, err
+		}
+		isRangeNotSatisfiable := offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable
+		if (resp.StatusCode < 200 || resp.StatusCode > 299) && !isRangeNotSatisfiable {
+			resp.Body.Close()
+			return nil, 0, newHTTPStatusError(c.URL, resp)
+		}
+		if offset == 0 {
+			return resp, 0, nil
+		}
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+			start, _, total, err := util.ParseContentRange(resp.Header.Get("Content-Range"))
+			if err == nil && start == offset && (meta.Length <= 0 || total == meta.Length) {
+				return resp, offset, nil
+			}
+		case http.StatusRequestedRangeNotSatisfiable:
+			_, _, total, err := util.ParseContentRange(resp.Header.Get("Content-Range"))
+			if err == nil && total == offset {
+				resp.Body.Close()
+				return nil, offset, nil
+			}
+		case http.StatusOK:
+			// The server ignores the Range header or the validator has changed,
+			// the response contains the complete content
+			c.removePartialFiles()
+			return resp, 0, nil
+		}
+		// The partial file can not be resumed, restart the download from scratch
+		resp.Body.Close()
+		c.removePartialFiles()
+		offset = 0
+	}
+}
+
+// checkContentType returns a ContentTypeError if the Content-Type of specified response
+// does not match the job type of URLDownloadTask.
+// Job types without expected Content-Type families and responses with unknown Content-Type will not be checked
+func (c *URLDownloadTask) checkContentType(resp *http.Response) error {
+	expectedFamilies, ok := expectedContentTypeFamilies[c.JobType]
+	if !ok {
+		return nil
+	}
+	contentType := resp.Header.Get("Content-Type")
+	family := util.GetMediaTypeFamily(contentType)
+	if family == "" || util.IsStringSliceContainText(expectedFamilies, family) {
+		return nil
+	}
+	return &ContentTypeError{
+		URL:              c.URL,
+		ContentType:      contentType,
+		ExpectedFamilies: expectedFamilies,
+	}
+}
+
+// addProgressBar adds a download progress bar to progressBar,
+// the bar starts from offset when resuming a partial file
+func (c *URLDownloadTask) addProgressBar(progressBar *mpb.Progress, contentLength int64, offset int64, attempt int) *mpb.Bar {
+	total := contentLength
+	if total > 0 {
+		total += offset
+	}
+	taskName := progressBarTaskName(c.JobType, attempt)
 	bar := progressBar.AddBar(
-		resp.ContentLength,
+		total,
 		mpb.PrependDecorators(
 			decor.Name(taskName, decor.WC{W: len(taskName) + 1, C: decor.DidentRight}),
-			decor.Name(util.GetFirstNCharacters(c.JobName, 20), decor.WCSyncSpaceR),
+			decor.Name(util.GetFirstNCharacters(c.DisplayName(), 20), decor.WCSyncSpaceR),
 		),
 		mpb.AppendDecorators(
 			decor.EwmaSpeed(decor.UnitKiB, "% .1f", 60),
 			decor.Percentage(decor.WC{W: 6}),
 		),
 	)
-	proxyReader := bar.ProxyReader(resp.Body)
-	_, err = io.Copy(out, proxyReader)
-	return err
+	if offset > 0 {
+		bar.SetCurrent(offset)
+	}
+	return bar
 }
 
-// IsDestFileExist returns whether the URLDownloadTask destination file exists
+// finalizePartialFile renames the partial file to URLDownloadTask.Dest
+// and removes the partial download metadata file
+func (c *URLDownloadTask) finalizePartialFile() error {
+	err := os.Rename(c.PartialFilePath(), c.Dest)
+	if err != nil {
+		return err
+	}
+	_ = os.Remove(c.partialMetaFilePath())
+	return nil
+}
+
+// IsDestFileExist returns whether the URLDownloadTask destination file exists,
+// the destination file only exists after the partial file has been finalized
 func (c *URLDownloadTask) IsDestFileExist() bool {
-	return util.IsPathExist(c.Dest)
+	return util.IsFileExist(c.Dest)
 }
 
-// RemoveDownloadedTask removes all downloaded tasks from EpisodeDownloadTask
-func (e *EpisodeDownloadTask) RemoveDownloadedTask() {
+// RemoveDownloadedTask removes all downloaded tasks from EpisodeDownloadTask,
+// verifyMode determines how to check whether a task is downloaded
+func (e *EpisodeDownloadTask) RemoveDownloadedTask(httpClient *http.Client, verifyMode VerifyMode) {
 	for index, enclosureDownloadTask := range e.EnclosureDownloadTasks {
-		if enclosureDownloadTask.IsDestFileExist() {
+		if enclosureDownloadTask.IsDownloaded(httpClient, verifyMode) {
 			e.EnclosureDownloadTasks[index] = nil
 		}
 	}
-	if e.CoverDownloadTask != nil && e.CoverDownloadTask.IsDestFileExist() {
+	if e.CoverDownloadTask != nil && e.CoverDownloadTask.IsDownloaded(httpClient, verifyMode) {
 		e.CoverDownloadTask = nil
 	}
-	if e.ShownotesDownloadTask != nil && e.ShownotesDownloadTask.IsDestFileExist() {
+	if e.ShownotesDownloadTask != nil && e.ShownotesDownloadTask.IsDownloaded(verifyMode) {
 		e.ShownotesDownloadTask = nil
 	}
+	for index, transcriptDownloadTask := range e.TranscriptDownloadTasks {
+		if transcriptDownloadTask != nil && transcriptDownloadTask.IsDownloaded(httpClient, verifyMode) {
+			e.TranscriptDownloadTasks[index] = nil
+		}
+	}
+	if e.ChapterTask != nil && e.ChapterTask.IsDownloaded() {
+		e.ChapterTask = nil
+	}
 }
 
 // Mkdir creates the episode download destination directory
@@ -156,13 +457,49 @@ func (e *EpisodeDownloadTask) Mkdir() error {
 	return util.EnsureDirAll(e.BaseDestDir)
 }
 
-// RemoveDownloadedTask removes all downloaded cover download tasks from PodcastDownloadTask
-func (p *PodcastDownloadTask) RemoveDownloadedTask() {
-	if p.CoverDownloadTask != nil && p.CoverDownloadTask.IsDestFileExist() {
+// RemoveDownloadedTask removes all downloaded cover download tasks from PodcastDownloadTask,
+// verifyMode determines how to check whether a task is downloaded
+func (p *PodcastDownloadTask) RemoveDownloadedTask(httpClient *http.Client, verifyMode VerifyMode) {
+	if p.CoverDownloadTask != nil && p.CoverDownloadTask.IsDownloaded(httpClient, verifyMode) {
 		p.CoverDownloadTask = nil
 	}
 }
 
+// IsFeedOnly returns whether the feed is the only file left to download in PodcastDownloadTask,
+// which means the cover and all episodes have been downloaded
+func (p *PodcastDownloadTask) IsFeedOnly() bool {
+	if p.CoverDownloadTask != nil {
+		return false
+	}
+	for _, episodeDownloadTask := range p.EpisodeDownloadTasks {
+		if episodeDownloadTask.CoverDownloadTask != nil || episodeDownloadTask.ShownotesDownloadTask != nil || episodeDownloadTask.ChapterTask != nil {
+			return false
+		}
+		for _, enclosureDownloadTask := range episodeDownloadTask.EnclosureDownloadTasks {
+			if enclosureDownloadTask != nil {
+				return false
+			}
+		}
+		for _, transcriptDownloadTask := range episodeDownloadTask.TranscriptDownloadTasks {
+			if transcriptDownloadTask != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// OutputFiles returns the paths of the files written by all tasks left in PodcastDownloadTask
+func (p *PodcastDownloadTask) OutputFiles() []string {
+	var files []string
+	for _, item := range p.queueItems(0) {
+		if outputTask, ok := item.task.(OutputTask); ok {
+			files = append(files, outputTask.OutputFiles()...)
+		}
+	}
+	return files
+}
+
 // Mkdir creates the podcast download destination directory
 func (p *PodcastDownloadTask) Mkdir() error {
 	return util.EnsureDirAll(p.BaseDestDir)
@@ -170,7 +507,7 @@ func (p *PodcastDownloadTask) Mkdir() error {
 
 // RemoveDownloadedTaskWithProgress removes all downloaded tasks from PodcastDownloadTask
 // and creates podcast download destination directory with progress bar
-func (p *PodcastDownloadTask) RemoveDownloadedTaskWithProgress(progressBar *mpb.Progress) {
+func (p *PodcastDownloadTask) RemoveDownloadedTaskWithProgress(progressBar *mpb.Progress, httpClient *http.Client, verifyMode VerifyMode) {
 	taskName := "[Check]"
 	job := p.PodcastTitle
 	bar := progressBar.AddBar(
@@ -182,9 +519,9 @@ func (p *PodcastDownloadTask) RemoveDownloadedTaskWithProgress(progressBar *mpb.
 		),
 		mpb.AppendDecorators(decor.Percentage(decor.WC{W: 5})),
 	)
-	p.RemoveDownloadedTask()
+	p.RemoveDownloadedTask(httpClient, verifyMode)
 	for index := range p.EpisodeDownloadTasks {
-		p.EpisodeDownloadTasks[index].RemoveDownloadedTask()
+		p.EpisodeDownloadTasks[index].RemoveDownloadedTask(httpClient, verifyMode)
 		bar.IncrBy(1)
 	}
 }
@@ -2,6 +2,7 @@ package podownloader
 
 import (
 	"github.com/vbauerster/mpb/v8"
+	"net/http"
 	"sync"
 )
 
@@ -9,7 +10,9 @@ import (
 type DownloadTaskIterator struct {
 	CurrentIndex         int
 	PodcastDownloadTasks []*PodcastDownloadTask
-	lock                 *sync.Mutex
+	// ProgressMode determines whether the progress bars of checking downloaded tasks are displayed
+	ProgressMode ProgressMode
+	lock         *sync.Mutex
 }
 
 // NewDownloadTaskIterator returns *DownloadTaskIterator init from []*PodcastDownloadTask
@@ -17,6 +20,7 @@ func NewDownloadTaskIterator(podcastDownloadTasks []*PodcastDownloadTask) *Downl
 	return &DownloadTaskIterator{
 		CurrentIndex:         0,
 		PodcastDownloadTasks: podcastDownloadTasks,
+		ProgressMode:         ProgressModeBar,
 		lock:                 &sync.Mutex{},
 	}
 }
@@ -50,26 +54,29 @@ func (dti *DownloadTaskIterator) Next() *PodcastDownloadTask {
 // startRemoveDownloadedTask calls PodcastDownloadTask.RemoveDownloadedTaskWithProgress
 // to remove downloaded task and will start a startRemoveDownloadedTask goroutine
 // if the iterator has next item
-func startRemoveDownloadedTask(doneWg *sync.WaitGroup, progressBar *mpb.Progress, task *PodcastDownloadTask, downloadTaskIterator *DownloadTaskIterator) {
+func startRemoveDownloadedTask(doneWg *sync.WaitGroup, progressBar *mpb.Progress, httpClient *http.Client, verifyMode VerifyMode, task *PodcastDownloadTask, downloadTaskIterator *DownloadTaskIterator) {
 	defer doneWg.Done()
-	task.RemoveDownloadedTaskWithProgress(progressBar)
+	task.RemoveDownloadedTaskWithProgress(progressBar, httpClient, verifyMode)
 	newTask := downloadTaskIterator.Next()
 	if newTask != nil {
-		go startRemoveDownloadedTask(doneWg, progressBar, newTask, downloadTaskIterator)
+		go startRemoveDownloadedTask(doneWg, progressBar, httpClient, verifyMode, newTask, downloadTaskIterator)
 	}
 }
 
 // RemoveDownloadedTask will start ThreadCount startRemoveDownloadedTask goroutines to remove
-// downloaded tasks
-func (dti *DownloadTaskIterator) RemoveDownloadedTask(threadCount int) {
+// downloaded tasks, verifyMode determines how to check whether a task is downloaded.
+// The tasks left with the same URL as a removed task are linked from its destination instead of downloading again
+func (dti *DownloadTaskIterator) RemoveDownloadedTask(threadCount int, httpClient *http.Client, verifyMode VerifyMode) {
+	firstDests := getFirstURLDests(dti.PodcastDownloadTasks)
 	doneWg := new(sync.WaitGroup)
 	doneWg.Add(dti.GetLeftLength())
-	progressBar := mpb.New(mpb.WithWaitGroup(doneWg))
+	progressBar := NewProgress(dti.ProgressMode, mpb.WithWaitGroup(doneWg))
 	for i := 0; i < threadCount; i++ {
 		task := dti.Next()
 		if task != nil {
-			go startRemoveDownloadedTask(doneWg, progressBar, task, dti)
+			go startRemoveDownloadedTask(doneWg, progressBar, httpClient, verifyMode, task, dti)
 		}
 	}
 	progressBar.Wait()
+	linkDownloadedDuplicates(dti.PodcastDownloadTasks, firstDests)
 }
@@ -0,0 +1,161 @@
+package podownloader
+
+import (
+	"PoDownloader/util"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newContentServer(content []byte, etag string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("ETag", etag)
+		http.ServeContent(writer, request, "episode.mp3", time.Time{}, bytes.NewReader(content))
+	}))
+}
+
+func TestURLDownloadTask_Download(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	server := newContentServer(content, "\"v1\"")
+	defer server.Close()
+	task := &URLDownloadTask{URL: server.URL, Dest: filepath.Join(t.TempDir(), "episode.mp3")}
+	assert.Nil(t, task.Download(&http.Client{}, DefaultDownloadOptions()))
+	downloaded, err := os.ReadFile(task.Dest)
+	assert.Nil(t, err)
+	assert.Equal(t, content, downloaded)
+	assert.False(t, util.IsPathExist(task.PartialFilePath()))
+	assert.False(t, util.IsPathExist(task.partialMetaFilePath()))
+}
+
+func TestURLDownloadTask_DownloadResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	server := newContentServer(content, "\"v1\"")
+	defer server.Close()
+	task := &URLDownloadTask{URL: server.URL, Dest: filepath.Join(t.TempDir(), "episode.mp3")}
+	// The partial file contains a marker which would be replaced if the download restarted
+	partial := append([]byte("X"), content[1:4000]...)
+	assert.Nil(t, os.WriteFile(task.PartialFilePath(), partial, 0644))
+	assert.Nil(t, (&partialDownloadMeta{URL: server.URL, ETag: "\"v1\""}).save(task.partialMetaFilePath()))
+	assert.Nil(t, task.Download(&http.Client{}, DefaultDownloadOptions()))
+	downloaded, err := os.ReadFile(task.Dest)
+	assert.Nil(t, err)
+	assert.Equal(t, append(partial, content[4000:]...), downloaded)
+}
+
+func TestURLDownloadTask_DownloadRestartWhenValidatorChanged(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	server := newContentServer(content, "\"v2\"")
+	defer server.Close()
+	task := &URLDownloadTask{URL: server.URL, Dest: filepath.Join(t.TempDir(), "episode.mp3")}
+	assert.Nil(t, os.WriteFile(task.PartialFilePath(), []byte("stale content"), 0644))
+	assert.Nil(t, (&partialDownloadMeta{URL: server.URL, ETag: "\"v1\""}).save(task.partialMetaFilePath()))
+	assert.Nil(t, task.Download(&http.Client{}, DefaultDownloadOptions()))
+	downloaded, err := os.ReadFile(task.Dest)
+	assert.Nil(t, err)
+	assert.Equal(t, content, downloaded)
+}
+
+func TestURLDownloadTask_DownloadRestartWithoutValidator(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	server := newContentServer(content, "")
+	defer server.Close()
+	task := &URLDownloadTask{URL: server.URL, Dest: filepath.Join(t.TempDir(), "episode.mp3")}
+	// A partial file without a validator can not be resumed even if the complete length is known
+	assert.Nil(t, os.WriteFile(task.PartialFilePath(), bytes.Repeat([]byte("x"), 4000), 0644))
+	assert.Nil(t, (&partialDownloadMeta{URL: server.URL, Length: int64(len(content))}).save(task.partialMetaFilePath()))
+	assert.Nil(t, task.Download(&http.Client{}, DefaultDownloadOptions()))
+	downloaded, err := os.ReadFile(task.Dest)
+	assert.Nil(t, err)
+	assert.Equal(t, content, downloaded)
+}
+
+func TestURLDownloadTask_DownloadCompletePartialFile(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	server := newContentServer(content, "\"v1\"")
+	defer server.Close()
+	task := &URLDownloadTask{URL: server.URL, Dest: filepath.Join(t.TempDir(), "episode.mp3")}
+	assert.Nil(t, os.WriteFile(task.PartialFilePath(), content, 0644))
+	assert.Nil(t, (&partialDownloadMeta{URL: server.URL, ETag: "\"v1\""}).save(task.partialMetaFilePath()))
+	assert.Nil(t, task.Download(&http.Client{}, DefaultDownloadOptions()))
+	downloaded, err := os.ReadFile(task.Dest)
+	assert.Nil(t, err)
+	assert.Equal(t, content, downloaded)
+}
+
+func TestURLDownloadTask_DownloadHTTPStatusError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	task := &URLDownloadTask{URL: server.URL, Dest: filepath.Join(t.TempDir(), "episode.mp3")}
+	err := task.Download(&http.Client{}, DefaultDownloadOptions())
+	var statusErr *HTTPStatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+	assert.Equal(t, server.URL, statusErr.URL)
+	assert.False(t, util.IsPathExist(task.Dest))
+}
+
+func TestURLDownloadTask_DownloadContentTypeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = writer.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+	task := &URLDownloadTask{JobType: JobTypeEnclosure, URL: server.URL, Dest: filepath.Join(t.TempDir(), "episode.mp3")}
+	options := DefaultDownloadOptions()
+	options.CheckContentType = true
+	err := task.Download(&http.Client{}, options)
+	var contentTypeErr *ContentTypeError
+	assert.ErrorAs(t, err, &contentTypeErr)
+	assert.False(t, util.IsPathExist(task.Dest))
+
+	// Content-Type will not be checked when the check is disabled
+	assert.Nil(t, task.Download(&http.Client{}, DefaultDownloadOptions()))
+	assert.True(t, util.IsPathExist(task.Dest))
+}
+
+func TestURLDownloadTask_IsDownloaded(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	server := newContentServer(content, "\"v1\"")
+	defer server.Close()
+	task := &URLDownloadTask{URL: server.URL, Dest: filepath.Join(t.TempDir(), "episode.mp3"), Length: int64(len(content))}
+	assert.False(t, task.IsDownloaded(&http.Client{}, VerifyModeExist))
+
+	assert.Nil(t, os.WriteFile(task.Dest, content[:4000], 0644))
+	assert.True(t, task.IsDownloaded(&http.Client{}, VerifyModeExist))
+	assert.False(t, task.IsDownloaded(&http.Client{}, VerifyModeSize))
+	// The mismatching file is kept until it is replaced by a download from scratch, it is never resumed
+	assert.True(t, util.IsPathExist(task.Dest))
+	assert.Nil(t, os.WriteFile(task.Dest, bytes.Repeat([]byte("x"), 4000), 0644))
+	assert.Nil(t, task.Download(&http.Client{}, DefaultDownloadOptions()))
+	downloaded, err := os.ReadFile(task.Dest)
+	assert.Nil(t, err)
+	assert.Equal(t, content, downloaded)
+	assert.False(t, util.IsPathExist(task.PartialFilePath()))
+	assert.True(t, task.IsDownloaded(&http.Client{}, VerifyModeSize))
+
+	// Strict mode prefers the remote Content-Length over the length declared in the feed
+	task.Length = 1
+	assert.False(t, task.IsDownloaded(&http.Client{}, VerifyModeSize))
+	assert.True(t, util.IsPathExist(task.Dest))
+	assert.True(t, task.IsDownloaded(&http.Client{}, VerifyModeStrict))
+}
+
+func TestPodcastDownloadTask_IsFeedOnly(t *testing.T) {
+	podcastDownloadTask := &PodcastDownloadTask{
+		RSSSaveTask: &TextSaveTask{JobType: JobTypeRSS, Text: "rss", Dest: "rss.xml"},
+		EpisodeDownloadTasks: []*EpisodeDownloadTask{
+			{EnclosureDownloadTasks: []*URLDownloadTask{nil}},
+		},
+	}
+	assert.True(t, podcastDownloadTask.IsFeedOnly())
+	podcastDownloadTask.EpisodeDownloadTasks[0].TranscriptDownloadTasks = []*URLDownloadTask{{JobType: JobTypeTranscript}}
+	assert.False(t, podcastDownloadTask.IsFeedOnly())
+	podcastDownloadTask.EpisodeDownloadTasks[0].TranscriptDownloadTasks[0] = nil
+	podcastDownloadTask.EpisodeDownloadTasks[0].EnclosureDownloadTasks[0] = &URLDownloadTask{JobType: JobTypeEnclosure}
+	assert.False(t, podcastDownloadTask.IsFeedOnly())
+}
@@ -0,0 +1,83 @@
+package podownloader
+
+import (
+	"PoDownloader/util"
+	"fmt"
+	"net/http"
+)
+
+// VerifyMode determines how to check whether the destination file of a download task is already downloaded
+type VerifyMode string
+
+const (
+	// VerifyModeExist treats a download task as downloaded if its destination file exists
+	VerifyModeExist VerifyMode = "exist"
+	// VerifyModeSize additionally compares the size of the destination file against the length declared in the feed
+	VerifyModeSize VerifyMode = "size"
+	// VerifyModeStrict additionally compares the size of the destination file against
+	// the Content-Length of an HTTP HEAD request, and falls back to the length declared in the feed
+	VerifyModeStrict VerifyMode = "strict"
+)
+
+// ParseVerifyMode returns the VerifyMode corresponding to specified text
+func ParseVerifyMode(text string) (VerifyMode, error) {
+	switch verifyMode := VerifyMode(text); verifyMode {
+	case VerifyModeExist, VerifyModeSize, VerifyModeStrict:
+		return verifyMode, nil
+	}
+	return "", fmt.Errorf("unknown verify mode: %s, available modes: exist, size, strict", text)
+}
+
+// expectedLength returns the expected size of the URLDownloadTask destination file according to specified VerifyMode,
+// returns 0 if the expected size is unknown
+func (c *URLDownloadTask) expectedLength(httpClient *http.Client, verifyMode VerifyMode) int64 {
+	switch verifyMode {
+	case VerifyModeSize:
+		return c.Length
+	case VerifyModeStrict:
+		remoteFileSize, err := util.GetRemoteFileSize(httpClient, c.URL)
+		if err == nil && remoteFileSize > 0 {
+			return remoteFileSize
+		}
+		return c.Length
+	}
+	return 0
+}
+
+// IsDownloaded returns whether the URLDownloadTask destination file is already downloaded according to specified VerifyMode.
+// The destination file is only written when a download is completed, so a mismatching destination file means
+// the remote file has changed or the declared length is wrong, it is downloaded again from scratch and replaced
+// after the download is completed. IsDownloaded never modifies the destination file
+func (c *URLDownloadTask) IsDownloaded(httpClient *http.Client, verifyMode VerifyMode) bool {
+	if !c.IsDestFileExist() {
+		return false
+	}
+	expectedLength := c.expectedLength(httpClient, verifyMode)
+	if expectedLength <= 0 {
+		return true
+	}
+	fileSize, err := util.GetFileSize(c.Dest)
+	if err != nil {
+		return false
+	}
+	return fileSize == expectedLength
+}
+
+// IsDownloaded returns whether the TextSaveTask destination file is already saved according to specified VerifyMode,
+// the size of the destination file will be compared with the size of TextSaveTask.Text unless VerifyModeExist is used
+func (t *TextSaveTask) IsDownloaded(verifyMode VerifyMode) bool {
+	if !t.IsDestFileExist() {
+		return false
+	}
+	if verifyMode == VerifyModeExist {
+		return true
+	}
+	fileSize, err := util.GetFileSize(t.Dest)
+	return err == nil && fileSize == int64(len(t.Text))
+}
+
+// IsDownloaded returns whether the ChapterTask destination file is already saved,
+// the size of the chapters is unknown so the file is only checked for existence
+func (t *ChapterTask) IsDownloaded() bool {
+	return t.IsDestFileExist()
+}
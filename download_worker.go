@@ -2,61 +2,288 @@ package podownloader
 
 import (
 	"PoDownloader/logger"
+	"PoDownloader/util"
+	"context"
 	"fmt"
 	"github.com/vbauerster/mpb/v8"
 	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // DownloadWorker is the worker to download podcasts
 type DownloadWorker struct {
-	doneWg              *sync.WaitGroup
-	httpClient          *http.Client
-	progressBar         *mpb.Progress
-	logger              *logger.Logger
-	TasksChan           chan interface{}
-	FailedTaskDestPaths []string
-	failedTaskListLock  *sync.Mutex
+	doneWg             *sync.WaitGroup
+	httpClient         *http.Client
+	progressBar        *mpb.Progress
+	logger             *logger.Logger
+	hostLimiter        *HostLimiter
+	threadBudget       *ThreadBudget
+	journal            *Journal
+	options            *DownloadOptions
+	drainFunc          context.CancelFunc
+	lowDiskSpace       int32
+	TasksChan          chan Task
+	TaskResults        []*TaskResult
+	FailedTasks        []*TaskResult
+	InterruptedTasks   []*TaskResult
+	UnstartedTasks     []Task
+	taskResultListLock *sync.Mutex
 }
 
 // NewDownloadWorker initializes and returns a DownloadWorker instance
-func NewDownloadWorker(doneWg *sync.WaitGroup, httpClient *http.Client, progressBar *mpb.Progress, logger *logger.Logger, threadCount int) *DownloadWorker {
-	var failedTaskDestPaths []string
+func NewDownloadWorker(doneWg *sync.WaitGroup, httpClient *http.Client, progressBar *mpb.Progress, logger *logger.Logger, hostLimiter *HostLimiter, threadBudget *ThreadBudget, journal *Journal, options *DownloadOptions, drainFunc context.CancelFunc, threadCount int) *DownloadWorker {
 	return &DownloadWorker{
-		doneWg:              doneWg,
-		httpClient:          httpClient,
-		progressBar:         progressBar,
-		logger:              logger,
-		TasksChan:           make(chan interface{}, threadCount),
-		FailedTaskDestPaths: failedTaskDestPaths,
-		failedTaskListLock:  &sync.Mutex{},
+		doneWg:             doneWg,
+		httpClient:         httpClient,
+		progressBar:        progressBar,
+		logger:             logger,
+		hostLimiter:        hostLimiter,
+		threadBudget:       threadBudget,
+		journal:            journal,
+		options:            options,
+		drainFunc:          drainFunc,
+		TasksChan:          make(chan Task, threadCount),
+		taskResultListLock: &sync.Mutex{},
 	}
 }
 
-// WorkerFunc is the download worker function
-func (dw *DownloadWorker) WorkerFunc() {
-	defer dw.doneWg.Done()
-	for task := range dw.TasksChan {
-		if urlDownloadTask, ok := task.(*URLDownloadTask); ok {
-			err := urlDownloadTask.DownloadWithProgress(dw.httpClient, dw.progressBar)
+// addTaskResult emits the result event of a task and appends the task result to DownloadWorker.TaskResults,
+// failed and interrupted tasks are appended to DownloadWorker.FailedTasks and DownloadWorker.InterruptedTasks as well
+func (dw *DownloadWorker) addTaskResult(taskResult *TaskResult) {
+	dw.emitEvent(newTaskResultEvent(taskResult))
+	dw.taskResultListLock.Lock()
+	defer dw.taskResultListLock.Unlock()
+	dw.TaskResults = append(dw.TaskResults, taskResult)
+	switch taskResult.Status {
+	case TaskResultFailed:
+		dw.FailedTasks = append(dw.FailedTasks, taskResult)
+	case TaskResultInterrupted:
+		dw.InterruptedTasks = append(dw.InterruptedTasks, taskResult)
+	}
+}
+
+// addUnstartedTask records a task that was not started or gave up retrying as pending in the journal,
+// so that it is started again when the run is resumed, and appends it to DownloadWorker.UnstartedTasks
+func (dw *DownloadWorker) addUnstartedTask(task Task, taskResult *TaskResult) {
+	dw.recordTaskState(task, TaskStatePending, taskResult.Err)
+	dw.emitEvent(newTaskResultEvent(taskResult))
+	dw.taskResultListLock.Lock()
+	dw.UnstartedTasks = append(dw.UnstartedTasks, task)
+	dw.taskResultListLock.Unlock()
+}
+
+// recordTaskState records the state of specified task to the journal if a journal is used
+func (dw *DownloadWorker) recordTaskState(task Task, state TaskState, err error) {
+	if dw.journal == nil {
+		return
+	}
+	journalErr := dw.journal.RecordState(task, state, err)
+	if journalErr != nil {
+		dw.logger.PrintlnToFile(fmt.Sprintf("Failed to record %s to the journal: %s", describeTask(task), journalErr))
+	}
+}
+
+// processDownloadedFiles deduplicates the files written by specified task with DownloadOptions.ContentIndex
+// and records their checksums to DownloadOptions.Manifest if they are used,
+// errors are written to the log file because the task has already succeeded
+func (dw *DownloadWorker) processDownloadedFiles(task Task) {
+	if dw.options.Manifest == nil && dw.options.ContentIndex == nil {
+		return
+	}
+	outputTask, ok := task.(OutputTask)
+	if !ok {
+		return
+	}
+	for _, path := range outputTask.OutputFiles() {
+		digest, size, err := util.GetFileSHA256(path)
+		if err != nil {
+			dw.logger.PrintlnToFile(fmt.Sprintf("Failed to hash %s: %s", path, err))
+			continue
+		}
+		if dw.options.ContentIndex != nil {
+			linkedPath, err := dw.options.ContentIndex.Dedupe(path, digest, size)
 			if err != nil {
-				dw.logger.Println(fmt.Sprintf("Failed to download %s: %s", urlDownloadTask.URL, err))
-				dw.failedTaskListLock.Lock()
-				dw.FailedTaskDestPaths = append(dw.FailedTaskDestPaths, urlDownloadTask.Dest)
-				dw.failedTaskListLock.Unlock()
-			} else {
-				dw.logger.PrintlnToFile(fmt.Sprintf("Successfully downloaded %s", urlDownloadTask.Dest))
+				dw.logger.PrintlnToFile(fmt.Sprintf("Failed to deduplicate %s: %s", path, err))
+			} else if linkedPath != "" {
+				dw.logger.PrintlnToFile(fmt.Sprintf("Deduplicated %s with a hard link to %s", path, linkedPath))
 			}
-		} else if textSaveTask, ok := task.(*TextSaveTask); ok {
-			err := textSaveTask.SaveWithProgress(dw.progressBar)
+		}
+		if dw.options.Manifest != nil {
+			err = dw.options.Manifest.RecordChecksum(path, digest, size)
 			if err != nil {
-				dw.logger.Println(fmt.Sprintf("Failed to save %s: %s", textSaveTask.Dest, err))
-				dw.failedTaskListLock.Lock()
-				dw.FailedTaskDestPaths = append(dw.FailedTaskDestPaths, textSaveTask.Dest)
-				dw.failedTaskListLock.Unlock()
-			} else {
-				dw.logger.PrintlnToFile(fmt.Sprintf("Successfully downloaded %s", textSaveTask.Dest))
+				dw.logger.PrintlnToFile(fmt.Sprintf("Failed to record the checksum of %s to the manifest: %s", path, err))
 			}
 		}
 	}
 }
+
+// IsLowDiskSpace returns whether the worker stopped starting new tasks because the free disk space dropped below
+// DownloadOptions.MinFreeSpace
+func (dw *DownloadWorker) IsLowDiskSpace() bool {
+	return atomic.LoadInt32(&dw.lowDiskSpace) != 0
+}
+
+// checkDiskSpace returns an InsufficientDiskSpaceError if the free disk space can not hold the declared size of the task
+// while keeping DownloadOptions.MinFreeSpace free
+func (dw *DownloadWorker) checkDiskSpace(task Task) error {
+	if dw.options.MinFreeSpace <= 0 {
+		return nil
+	}
+	required := dw.options.MinFreeSpace
+	if sizeHint := task.SizeHint(); sizeHint > 0 {
+		required += sizeHint
+	}
+	return CheckDiskSpace(task.Destination(), required)
+}
+
+// pauseForLowDiskSpace stops starting new tasks because the disk is running out of space,
+// the unstarted tasks are left pending in the journal so that they can be resumed after freeing some space
+func (dw *DownloadWorker) pauseForLowDiskSpace() {
+	if atomic.CompareAndSwapInt32(&dw.lowDiskSpace, 0, 1) {
+		dw.logger.Println("Disk is running out of space, no new download tasks will be started")
+		if dw.drainFunc != nil {
+			dw.drainFunc()
+		}
+	}
+}
+
+// emitEvent emits specified event to DownloadOptions.Events if the events are emitted
+func (dw *DownloadWorker) emitEvent(event *Event) {
+	if dw.options.Events != nil {
+		dw.options.Events.Emit(event)
+	}
+}
+
+// startProgressEvents emits an EventTaskProgress event of specified task every eventProgressInterval
+// while the number of transferred bytes is changing, the returned function stops emitting the events
+func (dw *DownloadWorker) startProgressEvents(task Task, stats *TaskStats) func() {
+	if dw.options.Events == nil {
+		return func() {}
+	}
+	var (
+		done    = make(chan struct{})
+		stopped = make(chan struct{})
+	)
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(eventProgressInterval)
+		defer ticker.Stop()
+		var lastBytes int64
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if bytes := stats.Bytes(); bytes != lastBytes {
+					lastBytes = bytes
+					dw.emitEvent(newTaskEvent(EventTaskProgress, task, stats))
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		// No progress events are emitted after the result event of the task
+		<-stopped
+	}
+}
+
+// executeWithRetry executes the task and retries it according to DownloadOptions.RetryPolicy,
+// returns the number of attempts, whether the retry was given up because drainCtx was done while waiting for
+// the next attempt, and the error of the last attempt.
+// No more retries will be made after drainCtx is done, and the task will be aborted when abortCtx is done
+func (dw *DownloadWorker) executeWithRetry(drainCtx context.Context, abortCtx context.Context, task Task, stats *TaskStats) (int, bool, error) {
+	retryPolicy := dw.options.RetryPolicy
+	for attempt := 1; ; attempt++ {
+		err := task.Execute(abortCtx, &TaskEnv{
+			HTTPClient:   dw.httpClient,
+			ProgressBar:  dw.progressBar,
+			Options:      dw.options,
+			ThreadBudget: dw.threadBudget,
+			HostLimiter:  dw.hostLimiter,
+			Stats:        stats,
+			Attempt:      attempt,
+		})
+		if err == nil || retryPolicy == nil || !retryPolicy.ShouldRetry(attempt, err) {
+			return attempt, false, err
+		}
+		backoff := retryPolicy.Backoff(attempt, err)
+		dw.logger.Println(fmt.Sprintf("Retrying %s in %s (attempt %d/%d): %s", describeTask(task), backoff.Round(time.Millisecond), attempt+1, retryPolicy.MaxAttempts, err))
+		timer := time.NewTimer(backoff)
+		select {
+		case <-drainCtx.Done():
+			timer.Stop()
+			return attempt, true, err
+		case <-timer.C:
+		}
+	}
+}
+
+// WorkerFunc is the download worker function, drainCtx stops retrying failed tasks
+// and abortCtx aborts the in-flight task
+func (dw *DownloadWorker) WorkerFunc(drainCtx context.Context, abortCtx context.Context) {
+	defer dw.doneWg.Done()
+	for task := range dw.TasksChan {
+		// The task was received before the queue stopped
+		if drainCtx.Err() != nil {
+			dw.taskResultListLock.Lock()
+			dw.UnstartedTasks = append(dw.UnstartedTasks, task)
+			dw.taskResultListLock.Unlock()
+			dw.hostLimiter.Release(getTaskHost(task))
+			continue
+		}
+		// Segmented downloads of other workers may be using the idle slots
+		dw.threadBudget.Acquire()
+		dw.runTask(drainCtx, abortCtx, task)
+		dw.threadBudget.Release(1)
+		// Release the host slot acquired by DownloadQueue.DeQueueAvailable
+		dw.hostLimiter.Release(getTaskHost(task))
+	}
+}
+
+// runTask executes a task and records its result,
+// the task states are recorded to the journal if a journal is used
+func (dw *DownloadWorker) runTask(drainCtx context.Context, abortCtx context.Context, task Task) {
+	stats := &TaskStats{}
+	err := dw.checkDiskSpace(task)
+	if err != nil {
+		dw.pauseForLowDiskSpace()
+		dw.logger.PrintlnToFile(fmt.Sprintf("Not starting %s: %s", describeTask(task), err))
+		dw.addUnstartedTask(task, newTaskResult(task, TaskResultUnstarted, stats, 0, 0, err))
+		return
+	}
+	dw.recordTaskState(task, TaskStateInProgress, nil)
+	startTime := time.Now()
+	dw.emitEvent(newTaskEvent(EventTaskStarted, task, stats))
+	stopProgressEvents := dw.startProgressEvents(task, stats)
+	attempts, drained, err := dw.executeWithRetry(drainCtx, abortCtx, task, stats)
+	stopProgressEvents()
+	duration := time.Since(startTime)
+	if drained {
+		dw.logger.PrintlnToFile(fmt.Sprintf("Gave up retrying %s: %s", describeTask(task), err))
+		// The task will be started again when the run is resumed, like the tasks that were never started
+		dw.addUnstartedTask(task, newTaskResult(task, TaskResultUnstarted, stats, duration, attempts, err))
+	} else if IsDiskSpaceError(err) {
+		dw.pauseForLowDiskSpace()
+		dw.logger.PrintlnToFile(fmt.Sprintf("Paused %s: %s", describeTask(task), err))
+		// The partial file is kept and resumed when the run is resumed after freeing some space
+		dw.recordTaskState(task, TaskStatePending, err)
+		dw.addTaskResult(newTaskResult(task, TaskResultInterrupted, stats, duration, attempts, err))
+	} else if err != nil && abortCtx.Err() != nil {
+		dw.logger.PrintlnToFile(fmt.Sprintf("Aborted %s", describeTask(task)))
+		// Aborted tasks will be started again when the run is resumed
+		dw.recordTaskState(task, TaskStatePending, err)
+		dw.addTaskResult(newTaskResult(task, TaskResultInterrupted, stats, duration, attempts, err))
+	} else if err != nil {
+		dw.logger.Println(fmt.Sprintf("Failed to download %s after %d attempt(s): %s", describeTask(task), attempts, err))
+		dw.recordTaskState(task, TaskStateFailed, err)
+		dw.addTaskResult(newTaskResult(task, TaskResultFailed, stats, duration, attempts, err))
+	} else {
+		dw.logger.PrintlnToFile(fmt.Sprintf("Successfully downloaded %s", describeTask(task)))
+		dw.processDownloadedFiles(task)
+		dw.recordTaskState(task, TaskStateDone, nil)
+		dw.addTaskResult(newTaskResult(task, TaskResultDone, stats, duration, attempts, nil))
+	}
+}
@@ -0,0 +1,177 @@
+package podownloader
+
+import (
+	"PoDownloader/logger"
+	"PoDownloader/util"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// eventProgressInterval is the interval between two EventTaskProgress events of a running task
+const eventProgressInterval = time.Second
+
+// EventType is the type of an Event
+type EventType string
+
+const (
+	// EventFeedParsed is emitted after a feed is parsed, Event.Status is done, not-modified or failed
+	EventFeedParsed EventType = "feed-parsed"
+	// EventTaskStarted is emitted when a download worker starts a task
+	EventTaskStarted EventType = "task-started"
+	// EventTaskProgress is emitted periodically while a task is transferring data
+	EventTaskProgress EventType = "task-progress"
+	// EventTaskFinished is emitted when a task is done
+	EventTaskFinished EventType = "task-finished"
+	// EventTaskFailed is emitted when a task failed after all attempts, was aborted or gave up retrying because of
+	// a cancellation signal, Event.Status is failed, interrupted or unstarted
+	EventTaskFailed EventType = "task-failed"
+)
+
+// Event is a structured event of a download run, the fields that do not apply to the event type are empty
+type Event struct {
+	Type    EventType `json:"event"`
+	Time    time.Time `json:"time"`
+	JobType string    `json:"jobType,omitempty"`
+	URL     string    `json:"url,omitempty"`
+	Title   string    `json:"title,omitempty"`
+	Dest    string    `json:"dest,omitempty"`
+	Status  string    `json:"status,omitempty"`
+	// Items is the number of items in a parsed feed
+	Items int `json:"items,omitempty"`
+	// Bytes is the number of bytes transferred by the task so far
+	Bytes int64 `json:"bytes,omitempty"`
+	// Size is the size declared for the destination file of the task, 0 if unknown
+	Size       int64  `json:"size,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+	Attempts   int    `json:"attempts,omitempty"`
+	HTTPStatus int    `json:"httpStatus,omitempty"`
+	ErrorClass string `json:"errorClass,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// NewFeedParsedEvent returns the EventFeedParsed event of specified feed result
+func NewFeedParsedEvent(feedResult *FeedResult) *Event {
+	record := feedResult.toReportRecord()
+	return &Event{
+		Type:       EventFeedParsed,
+		Time:       time.Now(),
+		URL:        record.URL,
+		Title:      record.Title,
+		Status:     record.Status,
+		Items:      record.Items,
+		DurationMs: record.DurationMs,
+		ErrorClass: record.ErrorClass,
+		Error:      record.Error,
+	}
+}
+
+// newTaskEvent returns an event of specified task, the statistics are read from stats
+func newTaskEvent(eventType EventType, task Task, stats *TaskStats) *Event {
+	return &Event{
+		Type:    eventType,
+		Time:    time.Now(),
+		JobType: task.Kind(),
+		URL:     getTaskURL(task),
+		Title:   task.DisplayName(),
+		Dest:    task.Destination(),
+		Bytes:   stats.Bytes(),
+		Size:    task.SizeHint(),
+	}
+}
+
+// newTaskResultEvent returns the EventTaskFinished or EventTaskFailed event of specified task result
+func newTaskResultEvent(taskResult *TaskResult) *Event {
+	eventType := EventTaskFinished
+	if taskResult.Status != TaskResultDone {
+		eventType = EventTaskFailed
+	}
+	record := taskResult.toReportRecord()
+	return &Event{
+		Type:       eventType,
+		Time:       time.Now(),
+		JobType:    record.JobType,
+		URL:        record.URL,
+		Title:      record.Title,
+		Dest:       record.Dest,
+		Status:     record.Status,
+		Bytes:      record.Bytes,
+		DurationMs: record.DurationMs,
+		Attempts:   record.Attempts,
+		HTTPStatus: record.HTTPStatus,
+		ErrorClass: record.ErrorClass,
+		Error:      record.Error,
+	}
+}
+
+// EventSink receives the events of a download run, implementations must be safe for concurrent use
+type EventSink interface {
+	// Emit handles an event, the event must not be modified after it is emitted
+	Emit(event *Event)
+}
+
+// JSONLinesEventSink writes each event to a writer as a line of JSON
+type JSONLinesEventSink struct {
+	writer io.Writer
+	lock   *sync.Mutex
+}
+
+// NewJSONLinesEventSink returns a JSONLinesEventSink instance that writes the events to specified writer
+func NewJSONLinesEventSink(writer io.Writer) *JSONLinesEventSink {
+	return &JSONLinesEventSink{
+		writer: writer,
+		lock:   &sync.Mutex{},
+	}
+}
+
+// Emit implements the EventSink interface, write errors are ignored so that the download is not affected
+func (s *JSONLinesEventSink) Emit(event *Event) {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, _ = s.writer.Write(append(line, '\n'))
+}
+
+// LogEventSink prints a plain line to stderr for each event, it replaces the progress bars in ProgressModePlain.
+// EventTaskProgress events are not printed to keep the output short, and EventTaskFailed events are not printed
+// because DownloadWorker already logs the failures
+type LogEventSink struct {
+	logger *logger.Logger
+}
+
+// NewLogEventSink returns a LogEventSink instance that prints the events with specified logger
+func NewLogEventSink(logger *logger.Logger) *LogEventSink {
+	return &LogEventSink{logger: logger}
+}
+
+// Emit implements the EventSink interface
+func (s *LogEventSink) Emit(event *Event) {
+	if line := formatEventLine(event); line != "" {
+		s.logger.PrintlnToStd(line)
+	}
+}
+
+// formatEventLine returns the plain line of specified event printed by LogEventSink, empty if the event is not printed
+func formatEventLine(event *Event) string {
+	switch event.Type {
+	case EventFeedParsed:
+		switch event.Status {
+		case "failed":
+			return fmt.Sprintf("Failed to parse %s: %s", event.URL, event.Error)
+		case "not-modified":
+			return fmt.Sprintf("Parsed %s: %s, not modified", event.URL, event.Title)
+		}
+		return fmt.Sprintf("Parsed %s: %s, %d item(s)", event.URL, event.Title, event.Items)
+	case EventTaskStarted:
+		return fmt.Sprintf("Started %s", event.Dest)
+	case EventTaskFinished:
+		duration := time.Duration(event.DurationMs) * time.Millisecond
+		return fmt.Sprintf("Finished %s, %s in %s", event.Dest, util.FormatByteSize(event.Bytes), duration)
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+package podownloader
+
+import (
+	"PoDownloader/logger"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingEventSink is an EventSink that records the emitted events
+type recordingEventSink struct {
+	events []*Event
+	lock   sync.Mutex
+}
+
+func (s *recordingEventSink) Emit(event *Event) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.events = append(s.events, event)
+}
+
+func TestJSONLinesEventSink_Emit(t *testing.T) {
+	var buffer bytes.Buffer
+	sink := NewJSONLinesEventSink(&buffer)
+	sink.Emit(NewFeedParsedEvent(&FeedResult{URL: "https://example.com/rss", Title: "Foo", Items: 3, Duration: time.Second}))
+	sink.Emit(NewFeedParsedEvent(&FeedResult{URL: "https://example.com/bar", Err: errors.New("foobar")}))
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	assert.Equal(t, 2, len(lines))
+	var event Event
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &event))
+	assert.Equal(t, EventFeedParsed, event.Type)
+	assert.Equal(t, "Foo", event.Title)
+	assert.Equal(t, "done", event.Status)
+	assert.Equal(t, 3, event.Items)
+	assert.Equal(t, int64(1000), event.DurationMs)
+	assert.Contains(t, lines[1], `"status":"failed"`)
+	assert.Contains(t, lines[1], `"error":"foobar"`)
+}
+
+func TestFormatEventLine(t *testing.T) {
+	assert.Equal(t, "Parsed https://example.com/rss: Foo, 3 item(s)", formatEventLine(&Event{Type: EventFeedParsed, URL: "https://example.com/rss", Title: "Foo", Status: "done", Items: 3}))
+	assert.Equal(t, "Parsed https://example.com/rss: Foo, not modified", formatEventLine(&Event{Type: EventFeedParsed, URL: "https://example.com/rss", Title: "Foo", Status: "not-modified"}))
+	assert.Equal(t, "Failed to parse https://example.com/rss: foobar", formatEventLine(&Event{Type: EventFeedParsed, URL: "https://example.com/rss", Status: "failed", Error: "foobar"}))
+	assert.Equal(t, "Started foo.mp3", formatEventLine(&Event{Type: EventTaskStarted, Dest: "foo.mp3"}))
+	assert.Equal(t, "Finished foo.mp3, 1.0 KiB in 1.5s", formatEventLine(&Event{Type: EventTaskFinished, Dest: "foo.mp3", Bytes: 1024, DurationMs: 1500}))
+	// Progress and failures are not printed as plain lines
+	assert.Equal(t, "", formatEventLine(&Event{Type: EventTaskProgress, Dest: "foo.mp3"}))
+	assert.Equal(t, "", formatEventLine(&Event{Type: EventTaskFailed, Dest: "foo.mp3"}))
+}
+
+func TestDownloadQueue_StartDownload_Events(t *testing.T) {
+	var executed int32
+	downloadQueue := &DownloadQueue{lock: &sync.Mutex{}}
+	downloadQueue.EnQueue(&fakeTask{dest: "a", executed: &executed})
+	downloadQueue.EnQueue(&fakeTask{dest: "b", executed: &executed, err: errors.New("foobar")})
+
+	sink := &recordingEventSink{}
+	options := DefaultDownloadOptions()
+	options.RetryPolicy = nil
+	options.ProgressMode = ProgressModePlain
+	options.Events = sink
+	testLogger, _ := logger.NewLogger("")
+	downloadQueue.StartDownload(1, &http.Client{}, testLogger, options)
+
+	var eventTypes []string
+	for _, event := range sink.events {
+		eventTypes = append(eventTypes, string(event.Type)+" "+event.Dest)
+	}
+	assert.Equal(t, []string{"task-started a", "task-finished a", "task-started b", "task-failed b"}, eventTypes)
+	assert.Equal(t, "failed", sink.events[3].Status)
+	assert.Equal(t, "foobar", sink.events[3].Error)
+	assert.Equal(t, 1, sink.events[3].Attempts)
+	assert.Equal(t, "b", sink.events[3].Title)
+}
+
+func TestDescribeTask(t *testing.T) {
+	assert.Equal(t, "Example - Episode 1 (/tmp/episode.mp3)", describeTask(&URLDownloadTask{JobName: "Example - Episode 1", Dest: "/tmp/episode.mp3"}))
+	assert.Equal(t, "/tmp/episode.mp3", describeTask(&URLDownloadTask{Dest: "/tmp/episode.mp3"}))
+}
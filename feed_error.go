@@ -0,0 +1,63 @@
+package podownloader
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FeedStage is the stage of fetching and parsing a feed at which a FeedError occurred
+type FeedStage string
+
+const (
+	// FeedStageFetch means the feed request could not be sent or the response could not be read
+	FeedStageFetch FeedStage = "fetch"
+	// FeedStageHTTPStatus means the server responded the feed request with a non-2xx status code
+	FeedStageHTTPStatus FeedStage = "http-status"
+	// FeedStageParse means the response is not a valid RSS, Atom or JSON feed
+	FeedStageParse FeedStage = "parse"
+	// FeedStageEmpty means the response body is empty
+	FeedStageEmpty FeedStage = "empty"
+)
+
+// FeedError is returned when a feed can not be fetched or parsed
+type FeedError struct {
+	URL string
+	// FinalURL is the URL the feed request was redirected to, empty if the request was not redirected
+	FinalURL string
+	Stage    FeedStage
+	// Err is the underlying error, nil for FeedStageEmpty
+	Err error
+}
+
+// Error implements the error interface
+func (e *FeedError) Error() string {
+	var message string
+	switch e.Stage {
+	case FeedStageFetch, FeedStageHTTPStatus:
+		message = fmt.Sprintf("failed to fetch feed: %v", e.Err)
+	case FeedStageParse:
+		message = fmt.Sprintf("failed to parse feed: %v", e.Err)
+	case FeedStageEmpty:
+		message = "empty feed"
+	default:
+		message = fmt.Sprintf("feed error: %v", e.Err)
+	}
+	if e.FinalURL != "" {
+		message += fmt.Sprintf(" (redirected to %s)", e.FinalURL)
+	}
+	return message
+}
+
+// Unwrap returns the underlying error, so that errors.Is and errors.As see through FeedError
+func (e *FeedError) Unwrap() error {
+	return e.Err
+}
+
+// GetFeedError returns the FeedError in the chain of specified error, nil if there is none
+func GetFeedError(err error) *FeedError {
+	var feedErr *FeedError
+	if errors.As(err, &feedErr) {
+		return feedErr
+	}
+	return nil
+}
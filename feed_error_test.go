@@ -0,0 +1,32 @@
+package podownloader
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"syscall"
+	"testing"
+)
+
+func TestFeedError(t *testing.T) {
+	statusErr := &HTTPStatusError{URL: "http://example.com/new.xml", StatusCode: 404}
+	feedErr := &FeedError{URL: "http://example.com/rss.xml", FinalURL: "http://example.com/new.xml", Stage: FeedStageHTTPStatus, Err: statusErr}
+	assert.Equal(t, "failed to fetch feed: unexpected HTTP status 404 Not Found from http://example.com/new.xml (redirected to http://example.com/new.xml)", feedErr.Error())
+	assert.True(t, errors.Is(feedErr, statusErr))
+	assert.Equal(t, ErrorClassHTTPStatus, ClassifyError(feedErr))
+	assert.Equal(t, feedErr, GetFeedError(fmt.Errorf("wrapped: %w", feedErr)))
+	assert.Nil(t, GetFeedError(statusErr))
+
+	assert.Equal(t, ErrorClassNetwork, ClassifyError(&FeedError{Stage: FeedStageFetch, Err: syscall.ECONNRESET}))
+	parseErr := &FeedError{Stage: FeedStageParse, Err: errors.New("Failed to detect feed type")}
+	assert.Equal(t, "failed to parse feed: Failed to detect feed type", parseErr.Error())
+	assert.Equal(t, ErrorClassFeedParse, ClassifyError(parseErr))
+	emptyErr := &FeedError{Stage: FeedStageEmpty}
+	assert.Equal(t, "empty feed", emptyErr.Error())
+	assert.Equal(t, ErrorClassEmptyFeed, ClassifyError(emptyErr))
+
+	feedResult := &FeedResult{URL: "http://example.com/rss.xml", Err: parseErr}
+	assert.Equal(t, parseErr, feedResult.FeedError())
+	assert.Equal(t, "feed-parse", feedResult.toReportRecord().ErrorClass)
+	assert.Nil(t, (&FeedResult{}).FeedError())
+}
@@ -0,0 +1,111 @@
+package podownloader
+
+import (
+	"PoDownloader/util"
+	"sync"
+	"time"
+)
+
+// HostLimiter limits the number of concurrent download tasks per host
+// and the minimum interval between two download tasks started on the same host
+type HostLimiter struct {
+	maxConcurrency int
+	minInterval    time.Duration
+	active         map[string]int
+	nextStart      map[string]time.Time
+	released       chan struct{}
+	lock           *sync.Mutex
+}
+
+// NewHostLimiter initializes and returns a HostLimiter instance,
+// maxConcurrency <= 0 means there is no limit on the number of concurrent download tasks per host
+func NewHostLimiter(maxConcurrency int, minInterval time.Duration) *HostLimiter {
+	return &HostLimiter{
+		maxConcurrency: maxConcurrency,
+		minInterval:    minInterval,
+		active:         make(map[string]int),
+		nextStart:      make(map[string]time.Time),
+		released:       make(chan struct{}, 1),
+		lock:           &sync.Mutex{},
+	}
+}
+
+// TryAcquire tries to acquire a slot of specified host at specified time.
+// When the slot can not be acquired, the returned duration is how long to wait before the host
+// becomes available because of the minimum interval, or 0 if the host is saturated
+// and the caller has to wait for a slot to be released
+func (hl *HostLimiter) TryAcquire(host string, now time.Time) (bool, time.Duration) {
+	if host == "" {
+		return true, 0
+	}
+	hl.lock.Lock()
+	defer hl.lock.Unlock()
+	if hl.maxConcurrency > 0 && hl.active[host] >= hl.maxConcurrency {
+		return false, 0
+	}
+	if nextStart, ok := hl.nextStart[host]; ok && now.Before(nextStart) {
+		return false, nextStart.Sub(now)
+	}
+	hl.active[host]++
+	if hl.minInterval > 0 {
+		hl.nextStart[host] = now.Add(hl.minInterval)
+	}
+	return true, 0
+}
+
+// TryAcquireExtra acquires up to n additional slots of specified host without blocking for the extra connections
+// of a task that already holds a slot of the host, returns the number of acquired slots.
+// The minimum interval between two tasks is not applied to the connections of a started task
+func (hl *HostLimiter) TryAcquireExtra(host string, n int) int {
+	if host == "" || n <= 0 {
+		return n
+	}
+	hl.lock.Lock()
+	defer hl.lock.Unlock()
+	acquired := n
+	if hl.maxConcurrency > 0 {
+		if free := hl.maxConcurrency - hl.active[host]; free < acquired {
+			acquired = free
+		}
+		if acquired < 0 {
+			acquired = 0
+		}
+	}
+	hl.active[host] += acquired
+	return acquired
+}
+
+// ReleaseExtra releases n slots of specified host acquired by TryAcquireExtra
+func (hl *HostLimiter) ReleaseExtra(host string, n int) {
+	for i := 0; i < n; i++ {
+		hl.Release(host)
+	}
+}
+
+// Release releases a slot of specified host acquired by TryAcquire
+func (hl *HostLimiter) Release(host string) {
+	if host == "" {
+		return
+	}
+	hl.lock.Lock()
+	if hl.active[host] > 0 {
+		hl.active[host]--
+	}
+	hl.lock.Unlock()
+	// Notify the waiting producer without blocking
+	select {
+	case hl.released <- struct{}{}:
+	default:
+	}
+}
+
+// Released returns a channel that receives a value after a slot is released
+func (hl *HostLimiter) Released() <-chan struct{} {
+	return hl.released
+}
+
+// getTaskHost returns the remote host of specified task,
+// returns an empty string if the task does not fetch its content from a remote URL
+func getTaskHost(task Task) string {
+	return util.GetURLHost(getTaskURL(task))
+}
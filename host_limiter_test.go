@@ -0,0 +1,81 @@
+package podownloader
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHostLimiter_TryAcquire(t *testing.T) {
+	now := time.Now()
+	hostLimiter := NewHostLimiter(2, time.Second)
+	ok, _ := hostLimiter.TryAcquire("example.org", now)
+	assert.True(t, ok)
+	// The minimum interval has not passed
+	ok, wait := hostLimiter.TryAcquire("example.org", now.Add(100*time.Millisecond))
+	assert.False(t, ok)
+	assert.Equal(t, 900*time.Millisecond, wait)
+	// Other hosts are not affected
+	ok, _ = hostLimiter.TryAcquire("example.com", now)
+	assert.True(t, ok)
+	ok, _ = hostLimiter.TryAcquire("example.org", now.Add(time.Second))
+	assert.True(t, ok)
+	// The host is saturated
+	ok, wait = hostLimiter.TryAcquire("example.org", now.Add(time.Hour))
+	assert.False(t, ok)
+	assert.Equal(t, time.Duration(0), wait)
+	hostLimiter.Release("example.org")
+	select {
+	case <-hostLimiter.Released():
+	default:
+		t.Fatal("release is not notified")
+	}
+	ok, _ = hostLimiter.TryAcquire("example.org", now.Add(time.Hour))
+	assert.True(t, ok)
+	// Tasks without host are never limited
+	ok, _ = hostLimiter.TryAcquire("", now)
+	assert.True(t, ok)
+}
+
+func TestHostLimiter_TryAcquireExtra(t *testing.T) {
+	hostLimiter := NewHostLimiter(3, time.Hour)
+	ok, _ := hostLimiter.TryAcquire("example.com", time.Now())
+	assert.True(t, ok)
+	// The minimum interval does not apply to the extra connections
+	assert.Equal(t, 2, hostLimiter.TryAcquireExtra("example.com", 4))
+	assert.Equal(t, 0, hostLimiter.TryAcquireExtra("example.com", 1))
+	hostLimiter.ReleaseExtra("example.com", 2)
+	assert.Equal(t, 1, hostLimiter.TryAcquireExtra("example.com", 1))
+	assert.Equal(t, 4, hostLimiter.TryAcquireExtra("", 4))
+	assert.Equal(t, 4, NewHostLimiter(0, 0).TryAcquireExtra("example.com", 4))
+}
+
+func TestDownloadQueue_DeQueueAvailable(t *testing.T) {
+	downloadQueue := &DownloadQueue{
+		items: []*queueItem{
+			{task: &URLDownloadTask{URL: "https://example.org/1.mp3"}},
+			{task: &URLDownloadTask{URL: "https://example.org/2.mp3"}},
+			{task: &URLDownloadTask{URL: "https://example.com/1.mp3"}},
+		},
+		lock: &sync.Mutex{},
+	}
+	hostLimiter := NewHostLimiter(1, 0)
+	task, _, err := downloadQueue.DeQueueAvailable(hostLimiter)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://example.org/1.mp3", task.(*URLDownloadTask).URL)
+	// example.org is saturated, the task of example.com should be returned
+	task, _, err = downloadQueue.DeQueueAvailable(hostLimiter)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://example.com/1.mp3", task.(*URLDownloadTask).URL)
+	task, wait, err := downloadQueue.DeQueueAvailable(hostLimiter)
+	assert.Nil(t, err)
+	assert.Nil(t, task)
+	assert.Equal(t, time.Duration(0), wait)
+	hostLimiter.Release("example.org")
+	task, _, err = downloadQueue.DeQueueAvailable(hostLimiter)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://example.org/2.mp3", task.(*URLDownloadTask).URL)
+	_, _, err = downloadQueue.DeQueueAvailable(hostLimiter)
+	assert.NotNil(t, err)
+}
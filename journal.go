@@ -0,0 +1,287 @@
+package podownloader
+
+import (
+	"PoDownloader/util"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// JournalFileName is the file name of the download journal in the output directory
+const JournalFileName = ".podownloader-journal.jsonl"
+
+// TaskState is the state of a task recorded in the download journal
+type TaskState string
+
+const (
+	// TaskStatePending means the task has not been started or has been interrupted
+	TaskStatePending TaskState = "pending"
+	// TaskStateInProgress means the task has been started but not finished,
+	// the run was killed if it is the last recorded state
+	TaskStateInProgress TaskState = "in-progress"
+	// TaskStateDone means the task has been completed successfully
+	TaskStateDone TaskState = "done"
+	// TaskStateFailed means the task has failed after all attempts
+	TaskStateFailed TaskState = "failed"
+)
+
+// Types of journal records
+const (
+	journalRecordTypePlan  = "plan"
+	journalRecordTypeState = "state"
+)
+
+// journalTaskTypes maps the names of the task types that can be journaled to the factories of the task types,
+// the tasks are serialized to JSON, so the task types must be JSON encodable
+var journalTaskTypes = map[string]func() Task{
+	"url":  func() Task { return &URLDownloadTask{} },
+	"text": func() Task { return &TextSaveTask{} },
+}
+
+// RegisterJournalTaskType registers a task type so that its tasks can be recorded in the download journal,
+// newTask returns an empty task of the type which the recorded task is decoded into
+func RegisterJournalTaskType(name string, newTask func() Task) {
+	journalTaskTypes[name] = newTask
+}
+
+// getJournalTaskTypeName returns the registered name of the type of specified task
+func getJournalTaskTypeName(task Task) (string, error) {
+	taskType := reflect.TypeOf(task)
+	for name, newTask := range journalTaskTypes {
+		if reflect.TypeOf(newTask()) == taskType {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("task type %T is not registered in the journal", task)
+}
+
+// journalRecord is a line of the download journal.
+// The journal starts with a plan record for every queued task in queue order,
+// followed by the state records appended as the run progresses
+type journalRecord struct {
+	Type     string          `json:"type"`
+	ID       int             `json:"id"`
+	TaskType string          `json:"taskType,omitempty"`
+	Task     json.RawMessage `json:"task,omitempty"`
+	State    TaskState       `json:"state,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Time     time.Time       `json:"time"`
+}
+
+// JournalEntry is a task recorded in the download journal with its last recorded state
+type JournalEntry struct {
+	id    int
+	Task  Task
+	State TaskState
+	// Error is the error message of the last failure, empty if the task has not failed
+	Error string
+}
+
+// Journal is an append-only file that records the plan and the task states of a download run,
+// so that a crashed or killed run can be resumed without re-planning
+type Journal struct {
+	path    string
+	file    *os.File
+	entries map[Task]*JournalEntry
+	lock    *sync.Mutex
+	// Entries are the recorded tasks in queue order
+	Entries []*JournalEntry
+}
+
+// CreateJournal writes the plan of specified tasks in queue order to a new journal file at path,
+// an existing journal file will be replaced
+func CreateJournal(path string, tasks []Task) (*Journal, error) {
+	var (
+		buffer  bytes.Buffer
+		now     = time.Now()
+		journal = &Journal{
+			path:    path,
+			entries: make(map[Task]*JournalEntry),
+			lock:    &sync.Mutex{},
+		}
+	)
+	for id, task := range tasks {
+		taskTypeName, err := getJournalTaskTypeName(task)
+		if err != nil {
+			return nil, err
+		}
+		taskBytes, err := json.Marshal(task)
+		if err != nil {
+			return nil, err
+		}
+		recordBytes, err := json.Marshal(&journalRecord{
+			Type:     journalRecordTypePlan,
+			ID:       id,
+			TaskType: taskTypeName,
+			Task:     taskBytes,
+			Time:     now,
+		})
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(recordBytes)
+		buffer.WriteByte('\n')
+		entry := &JournalEntry{id: id, Task: task, State: TaskStatePending}
+		journal.entries[task] = entry
+		journal.Entries = append(journal.Entries, entry)
+	}
+	err := util.EnsureDirAll(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	// Write the plan atomically so that a journal always contains the complete plan
+	err = util.WriteContentToFileAtomically(buffer.String(), path)
+	if err != nil {
+		return nil, err
+	}
+	return journal, journal.open()
+}
+
+// LoadJournal reads the journal file at path and returns the journal with the last recorded state of every task,
+// new state records will be appended to the journal file.
+// A truncated last line left by a killed run is ignored
+func LoadJournal(path string) (*Journal, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	journal := &Journal{
+		path:    path,
+		entries: make(map[Task]*JournalEntry),
+		lock:    &sync.Mutex{},
+	}
+	var (
+		entriesByID = make(map[int]*JournalEntry)
+		lines       = bytes.SplitAfter(content, []byte("\n"))
+		validLength int64
+	)
+	for index, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		var record journalRecord
+		err = json.Unmarshal(line, &record)
+		if err != nil || line[len(line)-1] != '\n' {
+			if index == len(lines)-1 {
+				// Remove the truncated last line so that new records are appended after a complete line
+				err = os.Truncate(path, validLength)
+				if err != nil {
+					return nil, err
+				}
+				break
+			}
+			return nil, fmt.Errorf("invalid journal record at line %d: %w", index+1, err)
+		}
+		validLength += int64(len(line))
+		switch record.Type {
+		case journalRecordTypePlan:
+			newTask, ok := journalTaskTypes[record.TaskType]
+			if !ok {
+				return nil, fmt.Errorf("unknown task type %s in journal record at line %d", record.TaskType, index+1)
+			}
+			task := newTask()
+			err = json.Unmarshal(record.Task, task)
+			if err != nil {
+				return nil, fmt.Errorf("invalid task in journal record at line %d: %w", index+1, err)
+			}
+			entry := &JournalEntry{id: record.ID, Task: task, State: TaskStatePending}
+			entriesByID[record.ID] = entry
+			journal.entries[task] = entry
+			journal.Entries = append(journal.Entries, entry)
+		case journalRecordTypeState:
+			entry, ok := entriesByID[record.ID]
+			if !ok {
+				return nil, fmt.Errorf("unknown task id %d in journal record at line %d", record.ID, index+1)
+			}
+			entry.State = record.State
+			entry.Error = record.Error
+		}
+	}
+	if len(journal.Entries) == 0 {
+		return nil, errors.New("journal is empty")
+	}
+	return journal, journal.open()
+}
+
+// open opens the journal file for appending state records
+func (j *Journal) open() error {
+	file, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	j.file = file
+	return nil
+}
+
+// RecordState appends a state record of specified task to the journal file,
+// err is recorded as the reason of the state if it is not nil
+func (j *Journal) RecordState(task Task, state TaskState, err error) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	entry, ok := j.entries[task]
+	if !ok {
+		return fmt.Errorf("task %s is not recorded in the journal", task.Destination())
+	}
+	record := &journalRecord{
+		Type:  journalRecordTypeState,
+		ID:    entry.id,
+		State: state,
+		Time:  time.Now(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	recordBytes, marshalErr := json.Marshal(record)
+	if marshalErr != nil {
+		return marshalErr
+	}
+	_, writeErr := j.file.Write(append(recordBytes, '\n'))
+	if writeErr != nil {
+		return writeErr
+	}
+	entry.State = state
+	entry.Error = record.Error
+	// Make sure the state survives a crash
+	return j.file.Sync()
+}
+
+// UnfinishedTasks returns the recorded tasks that are not done in queue order,
+// including the pending, in progress and failed tasks
+func (j *Journal) UnfinishedTasks() []Task {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	var tasks []Task
+	for _, entry := range j.Entries {
+		if entry.State != TaskStateDone {
+			tasks = append(tasks, entry.Task)
+		}
+	}
+	return tasks
+}
+
+// CountByState returns the number of recorded tasks in specified state
+func (j *Journal) CountByState(state TaskState) int {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	count := 0
+	for _, entry := range j.Entries {
+		if entry.State == state {
+			count++
+		}
+	}
+	return count
+}
+
+// Close closes the journal file
+func (j *Journal) Close() error {
+	if j.file == nil {
+		return nil
+	}
+	return j.file.Close()
+}
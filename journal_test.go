@@ -0,0 +1,88 @@
+package podownloader
+
+import (
+	"PoDownloader/logger"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJournal_Resume(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, JournalFileName)
+	tasks := []Task{
+		&URLDownloadTask{JobName: "a", JobType: JobTypeEnclosure, URL: "https://example.com/a.mp3", Dest: "a.mp3", Length: 100},
+		&TextSaveTask{JobName: "b", JobType: JobTypeShownotes, Text: "shownotes", Dest: "b.html"},
+		&URLDownloadTask{JobName: "c", JobType: JobTypeCover, URL: "https://example.com/c.jpg", Dest: "c.jpg"},
+		&URLDownloadTask{JobName: "d", JobType: JobTypeEnclosure, URL: "https://example.com/d.mp3", Dest: "d.mp3"},
+	}
+	journal, err := CreateJournal(journalPath, tasks)
+	assert.Nil(t, err)
+	assert.Nil(t, journal.RecordState(tasks[0], TaskStateInProgress, nil))
+	assert.Nil(t, journal.RecordState(tasks[1], TaskStateInProgress, nil))
+	assert.Nil(t, journal.RecordState(tasks[1], TaskStateDone, nil))
+	assert.Nil(t, journal.RecordState(tasks[2], TaskStateFailed, errors.New("foobar")))
+	assert.Nil(t, journal.Close())
+
+	// Simulate a record truncated by a killed run
+	file, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_APPEND, 0644)
+	assert.Nil(t, err)
+	_, err = file.WriteString(`{"type":"state","id":3,"sta`)
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+
+	journal, err = LoadJournal(journalPath)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, journal.CountByState(TaskStateDone))
+	assert.Equal(t, 1, journal.CountByState(TaskStateFailed))
+	assert.Equal(t, TaskStateInProgress, journal.Entries[0].State)
+	assert.Equal(t, "foobar", journal.Entries[2].Error)
+	unfinishedTasks := journal.UnfinishedTasks()
+	assert.Equal(t, 3, len(unfinishedTasks))
+	assert.Equal(t, tasks[0], unfinishedTasks[0])
+	assert.Equal(t, tasks[2], unfinishedTasks[1])
+	assert.Equal(t, tasks[3], unfinishedTasks[2])
+
+	// New records are appended after the last complete record
+	assert.Nil(t, journal.RecordState(unfinishedTasks[2], TaskStateDone, nil))
+	assert.Nil(t, journal.Close())
+	journal, err = LoadJournal(journalPath)
+	assert.Nil(t, err)
+	assert.Equal(t, TaskStateDone, journal.Entries[3].State)
+	assert.Nil(t, journal.Close())
+}
+
+func TestDownloadQueue_StartDownloadWithJournal(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, JournalFileName)
+	downloadQueue := NewDownloadQueueFromDownloadTasks([]*PodcastDownloadTask{
+		{
+			RSSDownloadTask: &URLDownloadTask{JobType: JobTypeRSS, URL: "http://127.0.0.1:0/rss.xml", Dest: filepath.Join(dir, "rss.xml")},
+			EpisodeDownloadTasks: []*EpisodeDownloadTask{
+				{ShownotesDownloadTask: &TextSaveTask{JobType: JobTypeShownotes, Text: "shownotes", Dest: filepath.Join(dir, "shownotes.html")}},
+			},
+		},
+	}, DownloadOrderFeed)
+	journal, err := downloadQueue.CreateJournal(journalPath)
+	assert.Nil(t, err)
+
+	testLogger, _ := logger.NewLogger("")
+	options := DefaultDownloadOptions()
+	options.RetryPolicy = nil
+	downloadResult := downloadQueue.StartDownload(2, &http.Client{}, testLogger, options)
+	assert.Equal(t, 1, len(downloadResult.FailedTasks))
+	assert.Nil(t, journal.Close())
+
+	journal, err = LoadJournal(journalPath)
+	assert.Nil(t, err)
+	defer journal.Close()
+	assert.Equal(t, TaskStateFailed, journal.Entries[0].State)
+	assert.Equal(t, TaskStateDone, journal.Entries[1].State)
+	resumedQueue := NewDownloadQueueFromJournal(journal)
+	assert.Equal(t, 1, resumedQueue.Length())
+	task, _ := resumedQueue.Front()
+	assert.Equal(t, JobTypeRSS, task.Kind())
+}
@@ -0,0 +1,246 @@
+package podownloader
+
+import (
+	"PoDownloader/util"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ManifestFileName is the file name of the checksum manifest in each podcast directory
+const ManifestFileName = ".podownloader-manifest.jsonl"
+
+// ManifestEntry is the checksum of a downloaded file recorded in the manifest
+type ManifestEntry struct {
+	// Path is the slash separated path of the file relative to the podcast directory
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	SHA256     string    `json:"sha256"`
+	RecordedAt time.Time `json:"recordedAt"`
+}
+
+// ManifestWriter records the checksums of downloaded files to the manifests of the podcast directories,
+// a podcast directory is a direct subdirectory of the root directory. It is safe for concurrent use
+type ManifestWriter struct {
+	root  string
+	files map[string]*os.File
+	lock  *sync.Mutex
+}
+
+// NewManifestWriter returns a ManifestWriter instance that records the files under specified root directory
+func NewManifestWriter(root string) *ManifestWriter {
+	return &ManifestWriter{
+		root:  root,
+		files: make(map[string]*os.File),
+		lock:  &sync.Mutex{},
+	}
+}
+
+// getManifestDir returns the podcast directory of specified file under root and the path relative to it,
+// files directly in root are recorded in the manifest of root
+func getManifestDir(root string, path string) (string, string, error) {
+	relPath, err := filepath.Rel(root, path)
+	if err != nil {
+		return "", "", err
+	}
+	relPath = filepath.ToSlash(relPath)
+	if relPath == ".." || strings.HasPrefix(relPath, "../") {
+		return "", "", fmt.Errorf("%s is not in %s", path, root)
+	}
+	podcastDir, fileRelPath, found := strings.Cut(relPath, "/")
+	if !found {
+		return root, relPath, nil
+	}
+	return filepath.Join(root, podcastDir), fileRelPath, nil
+}
+
+// Record hashes specified file and appends its checksum to the manifest of its podcast directory
+func (w *ManifestWriter) Record(path string) error {
+	digest, size, err := util.GetFileSHA256(path)
+	if err != nil {
+		return err
+	}
+	return w.RecordChecksum(path, digest, size)
+}
+
+// RecordChecksum appends the already computed checksum and size of specified file to the manifest of its podcast directory
+func (w *ManifestWriter) RecordChecksum(path string, digest string, size int64) error {
+	dir, relPath, err := getManifestDir(w.root, path)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(&ManifestEntry{
+		Path:       relPath,
+		Size:       size,
+		SHA256:     digest,
+		RecordedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	file, ok := w.files[dir]
+	if !ok {
+		file, err = os.OpenFile(filepath.Join(dir, ManifestFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		w.files[dir] = file
+	}
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the opened manifest files
+func (w *ManifestWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	var err error
+	for dir, file := range w.files {
+		closeErr := file.Close()
+		if err == nil {
+			err = closeErr
+		}
+		delete(w.files, dir)
+	}
+	return err
+}
+
+// LoadManifest returns the entries of specified manifest file in the order they were first recorded,
+// the last recorded entry of a path wins. A truncated last line is ignored
+func LoadManifest(path string) ([]*ManifestEntry, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		entries      []*ManifestEntry
+		entryIndexes = make(map[string]int)
+		lines        = bytes.SplitAfter(content, []byte("\n"))
+	)
+	for index, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		entry := &ManifestEntry{}
+		err = json.Unmarshal(line, entry)
+		if err != nil || line[len(line)-1] != '\n' {
+			if index == len(lines)-1 {
+				break
+			}
+			return nil, fmt.Errorf("invalid manifest entry at line %d: %w", index+1, err)
+		}
+		if entryIndex, ok := entryIndexes[entry.Path]; ok {
+			entries[entryIndex] = entry
+			continue
+		}
+		entryIndexes[entry.Path] = len(entries)
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// FindManifestDirs returns the directories that contain a manifest file, which are root and its direct subdirectories
+func FindManifestDirs(root string) ([]string, error) {
+	dirEntries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	if util.IsFileExist(filepath.Join(root, ManifestFileName)) {
+		dirs = append(dirs, root)
+	}
+	for _, dirEntry := range dirEntries {
+		dir := filepath.Join(root, dirEntry.Name())
+		if dirEntry.IsDir() && util.IsFileExist(filepath.Join(dir, ManifestFileName)) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
+// FileCheckStatus is the result of checking a file against its manifest entry
+type FileCheckStatus string
+
+const (
+	// FileCheckOK means the file matches the manifest entry
+	FileCheckOK FileCheckStatus = "ok"
+	// FileCheckMissing means the file does not exist
+	FileCheckMissing FileCheckStatus = "missing"
+	// FileCheckTruncated means the file is smaller than the recorded size
+	FileCheckTruncated FileCheckStatus = "truncated"
+	// FileCheckModified means the file is larger than the recorded size or its checksum does not match
+	FileCheckModified FileCheckStatus = "modified"
+	// FileCheckError means the file can not be read
+	FileCheckError FileCheckStatus = "error"
+)
+
+// FileCheckResult is the result of checking a file against its manifest entry
+type FileCheckResult struct {
+	Path     string
+	Status   FileCheckStatus
+	Expected *ManifestEntry
+	// Size is the current size of the file, 0 if the file is missing
+	Size int64
+	// SHA256 is the current checksum of the file, empty if the file is missing or truncated
+	SHA256 string
+	Err    error
+}
+
+// VerifyManifestEntry re-hashes the file of specified manifest entry in the podcast directory dir
+// and compares it with the entry
+func VerifyManifestEntry(dir string, entry *ManifestEntry) *FileCheckResult {
+	result := &FileCheckResult{
+		Path:     filepath.Join(dir, filepath.FromSlash(entry.Path)),
+		Expected: entry,
+	}
+	stat, err := os.Stat(result.Path)
+	if os.IsNotExist(err) {
+		result.Status = FileCheckMissing
+		return result
+	}
+	if err != nil {
+		result.Status = FileCheckError
+		result.Err = err
+		return result
+	}
+	result.Size = stat.Size()
+	if result.Size < entry.Size {
+		result.Status = FileCheckTruncated
+		return result
+	}
+	if result.Size > entry.Size {
+		result.Status = FileCheckModified
+		return result
+	}
+	result.SHA256, _, err = util.GetFileSHA256(result.Path)
+	if err != nil {
+		result.Status = FileCheckError
+		result.Err = err
+		return result
+	}
+	result.Status = FileCheckOK
+	if result.SHA256 != entry.SHA256 {
+		result.Status = FileCheckModified
+	}
+	return result
+}
+
+// VerifyManifest checks every file recorded in the manifest of specified podcast directory
+func VerifyManifest(dir string) ([]*FileCheckResult, error) {
+	entries, err := LoadManifest(filepath.Join(dir, ManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*FileCheckResult, 0, len(entries))
+	for _, entry := range entries {
+		results = append(results, VerifyManifestEntry(dir, entry))
+	}
+	return results, nil
+}
@@ -0,0 +1,119 @@
+package podownloader
+
+import (
+	"PoDownloader/logger"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestGetManifestDir(t *testing.T) {
+	root := filepath.Join("podcast")
+	dir, relPath, err := getManifestDir(root, filepath.Join(root, "foo", "bar", "episode.mp3"))
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(root, "foo"), dir)
+	assert.Equal(t, "bar/episode.mp3", relPath)
+	dir, relPath, err = getManifestDir(root, filepath.Join(root, "foo.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, root, dir)
+	assert.Equal(t, "foo.txt", relPath)
+	_, _, err = getManifestDir(root, filepath.Join("other", "foo.txt"))
+	assert.NotNil(t, err)
+}
+
+func TestManifestWriter(t *testing.T) {
+	root := t.TempDir()
+	podcastDir := filepath.Join(root, "foo")
+	assert.Nil(t, os.MkdirAll(filepath.Join(podcastDir, "episode"), 0755))
+	files := map[string]string{
+		"rss.xml":           "rss",
+		"episode/audio.mp3": "audio content",
+		"episode/cover.jpg": "cover content",
+		"episode/notes.txt": "shownotes",
+	}
+	manifestWriter := NewManifestWriter(root)
+	for path, content := range files {
+		filePath := filepath.Join(podcastDir, filepath.FromSlash(path))
+		assert.Nil(t, os.WriteFile(filePath, []byte(content), 0644))
+		assert.Nil(t, manifestWriter.Record(filePath))
+	}
+	// The last recorded entry of a path wins
+	assert.Nil(t, os.WriteFile(filepath.Join(podcastDir, "rss.xml"), []byte("new rss"), 0644))
+	assert.Nil(t, manifestWriter.Record(filepath.Join(podcastDir, "rss.xml")))
+	assert.Nil(t, manifestWriter.Close())
+
+	manifestDirs, err := FindManifestDirs(root)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{podcastDir}, manifestDirs)
+	entries, err := LoadManifest(filepath.Join(podcastDir, ManifestFileName))
+	assert.Nil(t, err)
+	assert.Equal(t, len(files), len(entries))
+
+	results, err := VerifyManifest(podcastDir)
+	assert.Nil(t, err)
+	for _, result := range results {
+		assert.Equal(t, FileCheckOK, result.Status, result.Path)
+	}
+
+	assert.Nil(t, os.Remove(filepath.Join(podcastDir, "episode", "audio.mp3")))
+	assert.Nil(t, os.WriteFile(filepath.Join(podcastDir, "episode", "cover.jpg"), []byte("cover"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(podcastDir, "episode", "notes.txt"), []byte("SHOWNOTES"), 0644))
+	results, err = VerifyManifest(podcastDir)
+	assert.Nil(t, err)
+	statuses := make(map[string]FileCheckStatus)
+	for _, result := range results {
+		statuses[result.Expected.Path] = result.Status
+	}
+	assert.Equal(t, map[string]FileCheckStatus{
+		"rss.xml":           FileCheckOK,
+		"episode/audio.mp3": FileCheckMissing,
+		"episode/cover.jpg": FileCheckTruncated,
+		"episode/notes.txt": FileCheckModified,
+	}, statuses)
+}
+
+func TestLoadManifestTruncated(t *testing.T) {
+	manifestPath := filepath.Join(t.TempDir(), ManifestFileName)
+	content := `{"path":"foo.mp3","size":3,"sha256":"abc","recordedAt":"2022-01-01T00:00:00Z"}` + "\n" + `{"path":"bar.mp3","si`
+	assert.Nil(t, os.WriteFile(manifestPath, []byte(content), 0644))
+	entries, err := LoadManifest(manifestPath)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "foo.mp3", entries[0].Path)
+}
+
+func TestDownloadQueue_StartDownloadWithManifest(t *testing.T) {
+	root := t.TempDir()
+	downloadQueue := &DownloadQueue{lock: &sync.Mutex{}}
+	downloadQueue.EnQueue(&TextSaveTask{JobType: JobTypeShownotes, Text: "shownotes", Dest: filepath.Join(root, "foo", "shownotes.html")})
+	var executed int32
+	downloadQueue.EnQueue(&fakeTask{dest: filepath.Join(root, "foo", "fake"), executed: &executed})
+
+	testLogger, _ := logger.NewLogger("")
+	options := DefaultDownloadOptions()
+	options.Manifest = NewManifestWriter(root)
+	downloadResult := downloadQueue.StartDownload(1, &http.Client{}, testLogger, options)
+	assert.Nil(t, options.Manifest.Close())
+	assert.Equal(t, 0, len(downloadResult.FailedTasks))
+
+	// Only the checksums of URLDownloadTask and TextSaveTask are recorded
+	entries, err := LoadManifest(filepath.Join(root, "foo", ManifestFileName))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "shownotes.html", entries[0].Path)
+	assert.Equal(t, int64(len("shownotes")), entries[0].Size)
+}
+
+func TestOutputTask_OutputFiles(t *testing.T) {
+	var task Task = &URLDownloadTask{Dest: "1.mp3", LinkDests: []string{"2.mp3"}}
+	assert.Equal(t, []string{"1.mp3", "2.mp3"}, task.(OutputTask).OutputFiles())
+	task = &TextSaveTask{Dest: "shownotes.html"}
+	assert.Equal(t, []string{"shownotes.html"}, task.(OutputTask).OutputFiles())
+	task = &ChapterTask{Dest: "chapters.json"}
+	assert.Equal(t, []string{"chapters.json"}, task.(OutputTask).OutputFiles())
+	_, ok := Task(&fakeTask{}).(OutputTask)
+	assert.False(t, ok)
+}
@@ -0,0 +1,104 @@
+package podownloader
+
+import (
+	"PoDownloader/util"
+	"encoding/json"
+	"net/http"
+	"os"
+)
+
+const (
+	// PartialFileSuffix is appended to the destination path of a URLDownloadTask
+	// to get the path of the file that holds the partially downloaded content
+	PartialFileSuffix = ".part"
+	// partialMetaFileSuffix is appended to the destination path of a URLDownloadTask
+	// to get the path of the partial download metadata file
+	partialMetaFileSuffix = ".part.json"
+)
+
+// partialDownloadMeta is the metadata of a partially downloaded file,
+// it records the validators of the response the partial file was downloaded from,
+// so that the next run can decide whether the partial file can be resumed
+type partialDownloadMeta struct {
+	URL          string `json:"url,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	// Length is the complete length of the content, 0 if unknown
+	Length int64 `json:"length,omitempty"`
+	// Segments are the byte ranges of a segmented download, nil if the content is downloaded in a single connection
+	Segments []*downloadSegment `json:"segments,omitempty"`
+}
+
+// newPartialDownloadMeta returns a partialDownloadMeta instance filled with the validators of specified response
+func newPartialDownloadMeta(url string, resp *http.Response) *partialDownloadMeta {
+	return &partialDownloadMeta{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Length:       resp.ContentLength,
+	}
+}
+
+// ifRangeValidator returns the validator that can be used as the value of the If-Range request header,
+// returns an empty string if there is no usable validator
+func (m *partialDownloadMeta) ifRangeValidator() string {
+	if util.IsStrongETag(m.ETag) {
+		return m.ETag
+	}
+	return m.LastModified
+}
+
+// loadPartialDownloadMeta reads the partial download metadata file from specified path
+func loadPartialDownloadMeta(path string) (*partialDownloadMeta, error) {
+	metaBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var meta partialDownloadMeta
+	err = json.Unmarshal(metaBytes, &meta)
+	if err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// save writes the partial download metadata to specified path
+func (m *partialDownloadMeta) save(path string) error {
+	metaBytes, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, metaBytes, 0644)
+}
+
+// PartialFilePath returns the path of the file that holds the partially downloaded content
+func (c *URLDownloadTask) PartialFilePath() string {
+	return c.Dest + PartialFileSuffix
+}
+
+// partialMetaFilePath returns the path of the partial download metadata file
+func (c *URLDownloadTask) partialMetaFilePath() string {
+	return c.Dest + partialMetaFileSuffix
+}
+
+// resumeOffset returns the size of the partial file and its metadata,
+// returns 0 when the partial file does not exist or can not be resumed safely.
+// A partial file can only be resumed if there is a validator for the If-Range request header,
+// otherwise the appended content may come from a changed remote file
+func (c *URLDownloadTask) resumeOffset() (int64, *partialDownloadMeta) {
+	meta, err := loadPartialDownloadMeta(c.partialMetaFilePath())
+	if err != nil || meta.URL != c.URL || len(meta.Segments) > 0 || meta.ifRangeValidator() == "" {
+		return 0, nil
+	}
+	size, err := util.GetFileSize(c.PartialFilePath())
+	if err != nil {
+		return 0, nil
+	}
+	return size, meta
+}
+
+// removePartialFiles removes the partial file and the partial download metadata file
+func (c *URLDownloadTask) removePartialFiles() {
+	_ = os.Remove(c.PartialFilePath())
+	_ = os.Remove(c.partialMetaFilePath())
+}
@@ -0,0 +1,157 @@
+package podcast
+
+import (
+	podownloader "PoDownloader"
+	"fmt"
+	ext "github.com/mmcdole/gofeed/extensions"
+	"html"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SimpleChapter is a Podlove Simple Chapters (psc:chapter) element of an item
+type SimpleChapter struct {
+	// Start is the normal play time of the chapter start, for example 00:01:30.500
+	Start string `json:"start,omitempty"`
+	Title string `json:"title,omitempty"`
+	Href  string `json:"href,omitempty"`
+	Image string `json:"image,omitempty"`
+}
+
+var (
+	// shownotesLineBreakRegex matches the HTML tags that break lines in the shownotes
+	shownotesLineBreakRegex = regexp.MustCompile(`(?i)<br\s*/?>|</(p|li|div|h[1-6]|tr)>`)
+	// shownotesTagRegex matches the HTML tags in the shownotes
+	shownotesTagRegex = regexp.MustCompile(`<[^>]*>`)
+	// shownotesChapterRegex matches a timestamp line of the shownotes such as "12:34 Topic", "[01:02:03] - Topic" or "(1:23) Topic"
+	shownotesChapterRegex = regexp.MustCompile(`^[\[(]?((?:\d{1,2}:)?\d{1,2}:\d{2}(?:\.\d+)?)[\])]?\s*(?:[-–—|:]\s*)?(\S.*)$`)
+)
+
+// newSimpleChapters returns the Podlove Simple Chapters in the extensions of a feed item,
+// gofeed keys the namespace http://podlove.org/simple-chapters by "psc" whatever prefix the feed declares
+func newSimpleChapters(extensions ext.Extensions) []*SimpleChapter {
+	var simpleChapters []*SimpleChapter
+	for _, chapters := range extensions["psc"]["chapters"] {
+		for _, chapter := range chapters.Children["chapter"] {
+			simpleChapters = append(simpleChapters, &SimpleChapter{
+				Start: chapter.Attrs["start"],
+				Title: chapter.Attrs["title"],
+				Href:  chapter.Attrs["href"],
+				Image: chapter.Attrs["image"],
+			})
+		}
+	}
+	return simpleChapters
+}
+
+// parseTimestamp parses a timestamp in the form of [[HH:]MM:]SS[.mmm] and returns the number of seconds
+func parseTimestamp(timestamp string) (float64, bool) {
+	parts := strings.Split(strings.TrimSpace(timestamp), ":")
+	if len(parts) > 3 {
+		return 0, false
+	}
+	var seconds float64
+	for index, part := range parts {
+		var (
+			value float64
+			err   error
+		)
+		if index == len(parts)-1 {
+			value, err = strconv.ParseFloat(part, 64)
+		} else {
+			var intValue int64
+			intValue, err = strconv.ParseInt(part, 10, 64)
+			value = float64(intValue)
+		}
+		if err != nil || value < 0 {
+			return 0, false
+		}
+		seconds = seconds*60 + value
+	}
+	return seconds, true
+}
+
+// getSimpleChapterList converts the Podlove Simple Chapters of the item to a ChapterList,
+// returns nil if the item has no valid simple chapters
+func (i *Item) getSimpleChapterList() *podownloader.ChapterList {
+	var chapters []*podownloader.Chapter
+	for _, simpleChapter := range i.SimpleChapters {
+		startTime, ok := parseTimestamp(simpleChapter.Start)
+		if !ok {
+			continue
+		}
+		chapters = append(chapters, &podownloader.Chapter{
+			StartTime: startTime,
+			Title:     simpleChapter.Title,
+			URL:       simpleChapter.Href,
+			Image:     simpleChapter.Image,
+		})
+	}
+	if len(chapters) == 0 {
+		return nil
+	}
+	return &podownloader.ChapterList{
+		Version:  podownloader.ChapterListVersion,
+		Source:   podownloader.ChapterSourceSimpleChapters,
+		Chapters: chapters,
+	}
+}
+
+// getShownotesChapterList extracts the chapters from the timestamp lines in the shownotes of the item,
+// returns nil unless there are at least two timestamp lines in ascending order
+func (i *Item) getShownotesChapterList() *podownloader.ChapterList {
+	text := shownotesLineBreakRegex.ReplaceAllString(i.Description, "\n")
+	text = html.UnescapeString(shownotesTagRegex.ReplaceAllString(text, ""))
+	var chapters []*podownloader.Chapter
+	for _, line := range strings.Split(text, "\n") {
+		matches := shownotesChapterRegex.FindStringSubmatch(strings.TrimSpace(line))
+		if matches == nil {
+			continue
+		}
+		startTime, ok := parseTimestamp(matches[1])
+		if !ok {
+			continue
+		}
+		if len(chapters) > 0 && startTime <= chapters[len(chapters)-1].StartTime {
+			// Timestamps out of order are not a table of contents, such as the timestamps mentioned in the text
+			return nil
+		}
+		chapters = append(chapters, &podownloader.Chapter{
+			StartTime: startTime,
+			Title:     strings.TrimSpace(matches[2]),
+		})
+	}
+	if len(chapters) < 2 {
+		return nil
+	}
+	return &podownloader.ChapterList{
+		Version:  podownloader.ChapterListVersion,
+		Source:   podownloader.ChapterSourceShownotes,
+		Chapters: chapters,
+	}
+}
+
+// GetChapterTask returns the task that saves the chapters of the item to chapters.json in destDir,
+// the chapters come from podcast:chapters first, then psc:chapters, and then the timestamp lines in the shownotes
+// if shownotesChapters is true. Returns nil if the item has no chapters
+func (i *Item) GetChapterTask(podcastTitle string, destDir string, shownotesChapters bool) *podownloader.ChapterTask {
+	chapterTask := &podownloader.ChapterTask{
+		JobName: fmt.Sprintf("%s - %s", podcastTitle, i.Title),
+		JobType: podownloader.JobTypeChapters,
+		Dest:    path.Join(destDir, "chapters.json"),
+	}
+	if i.PodcastExt != nil && i.PodcastExt.Chapters != nil && i.PodcastExt.Chapters.URL != "" {
+		chapterTask.URL = i.PodcastExt.Chapters.URL
+		return chapterTask
+	}
+	chapterTask.Chapters = i.getSimpleChapterList()
+	if chapterTask.Chapters == nil && shownotesChapters {
+		chapterTask.Chapters = i.getShownotesChapterList()
+	}
+	if chapterTask.Chapters == nil {
+		return nil
+	}
+	return chapterTask
+}
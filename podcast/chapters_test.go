@@ -0,0 +1,123 @@
+package podcast
+
+import (
+	podownloader "PoDownloader"
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testSimpleChaptersRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:psc="http://podlove.org/simple-chapters">
+<channel>
+<title>Example</title>
+<item>
+<title>Episode 1</title>
+<psc:chapters version="1.2">
+<psc:chapter start="0" title="Intro"/>
+<psc:chapter start="00:01:30.500" title="Topic" href="http://example.com/topic" image="http://example.com/topic.jpg"/>
+<psc:chapter start="invalid" title="Invalid"/>
+</psc:chapters>
+</item>
+</channel>
+</rss>`
+
+func TestParseTimestamp(t *testing.T) {
+	for text, expected := range map[string]float64{
+		"5":            5,
+		"1:05":         65,
+		"01:02:03":     3723,
+		"00:01:30.500": 90.5,
+	} {
+		seconds, ok := parseTimestamp(text)
+		assert.True(t, ok, text)
+		assert.Equal(t, expected, seconds, text)
+	}
+	for _, text := range []string{"", "1:2:3:4", "a:05", "1.5:00", "-1"} {
+		_, ok := parseTimestamp(text)
+		assert.False(t, ok, text)
+	}
+}
+
+func TestNewPodcastFromFeed_SimpleChapters(t *testing.T) {
+	feed, err := gofeed.NewParser().ParseString(testSimpleChaptersRSS)
+	assert.Nil(t, err)
+	podcast := newPodcastFromFeed("http://example.com/rss.xml", feed)
+	assert.Equal(t, []*SimpleChapter{
+		{Start: "0", Title: "Intro"},
+		{Start: "00:01:30.500", Title: "Topic", Href: "http://example.com/topic", Image: "http://example.com/topic.jpg"},
+		{Start: "invalid", Title: "Invalid"},
+	}, podcast.Items[0].SimpleChapters)
+
+	podcastDownloadTask := podcast.GetPodcastDownloadTask("/tmp", &http.Client{}, nil, nil)
+	chapterTask := podcastDownloadTask.EpisodeDownloadTasks[0].ChapterTask
+	assert.NotNil(t, chapterTask)
+	assert.Equal(t, "/tmp/Example/Episode 1/chapters.json", chapterTask.Dest)
+	assert.Equal(t, podownloader.JobTypeChapters, chapterTask.JobType)
+	assert.Equal(t, "Example - Episode 1", chapterTask.JobName)
+	assert.Equal(t, &podownloader.ChapterList{
+		Version: podownloader.ChapterListVersion,
+		Source:  podownloader.ChapterSourceSimpleChapters,
+		Chapters: []*podownloader.Chapter{
+			{StartTime: 0, Title: "Intro"},
+			{StartTime: 90.5, Title: "Topic", URL: "http://example.com/topic", Image: "http://example.com/topic.jpg"},
+		},
+	}, chapterTask.Chapters)
+}
+
+func TestNewPodcastFromFeed_SimpleChaptersPrefix(t *testing.T) {
+	content := strings.NewReplacer("xmlns:psc=", "xmlns:chapters=", "<psc:", "<chapters:", "</psc:", "</chapters:").Replace(testSimpleChaptersRSS)
+	feed, err := gofeed.NewParser().ParseString(content)
+	assert.Nil(t, err)
+	podcast := newPodcastFromFeed("http://example.com/rss.xml", feed)
+	assert.Len(t, podcast.Items[0].SimpleChapters, 3)
+	assert.Equal(t, "Intro", podcast.Items[0].SimpleChapters[0].Title)
+}
+
+func TestItem_GetShownotesChapterList(t *testing.T) {
+	item := &Item{Description: `<p>In this episode:</p>
+<ul><li>00:00 Intro</li><li>[05:30] - First &amp; second topic</li><li>(1:02:03) Outro</li></ul>
+<p>See https://example.com at 12:00 for details</p>`}
+	assert.Equal(t, &podownloader.ChapterList{
+		Version: podownloader.ChapterListVersion,
+		Source:  podownloader.ChapterSourceShownotes,
+		Chapters: []*podownloader.Chapter{
+			{StartTime: 0, Title: "Intro"},
+			{StartTime: 330, Title: "First & second topic"},
+			{StartTime: 3723, Title: "Outro"},
+		},
+	}, item.getShownotesChapterList())
+
+	// A single timestamp is not a table of contents
+	assert.Nil(t, (&Item{Description: "12:34 Topic"}).getShownotesChapterList())
+	// Timestamps out of order are not a table of contents
+	assert.Nil(t, (&Item{Description: "10:00 Second<br>05:00 First"}).getShownotesChapterList())
+}
+
+func TestItem_GetChapterTask(t *testing.T) {
+	item := &Item{
+		Title:          "Episode 1",
+		Description:    "00:00 Intro<br/>01:00 Topic",
+		PodcastExt:     &PodcastItemExtension{Chapters: &Chapters{URL: "http://example.com/1.json"}},
+		SimpleChapters: []*SimpleChapter{{Start: "0", Title: "Intro"}},
+	}
+	// podcast:chapters takes priority over psc:chapters
+	chapterTask := item.GetChapterTask("Example", "/tmp/Example/Episode 1", true)
+	assert.Equal(t, "http://example.com/1.json", chapterTask.URL)
+	assert.Nil(t, chapterTask.Chapters)
+
+	// psc:chapters takes priority over the shownotes
+	item.PodcastExt = nil
+	chapterTask = item.GetChapterTask("Example", "/tmp/Example/Episode 1", true)
+	assert.Equal(t, "", chapterTask.URL)
+	assert.Equal(t, podownloader.ChapterSourceSimpleChapters, chapterTask.Chapters.Source)
+
+	// The shownotes are used only if enabled
+	item.SimpleChapters = nil
+	chapterTask = item.GetChapterTask("Example", "/tmp/Example/Episode 1", true)
+	assert.Equal(t, podownloader.ChapterSourceShownotes, chapterTask.Chapters.Source)
+	assert.Equal(t, 2, len(chapterTask.Chapters.Chapters))
+	assert.Nil(t, item.GetChapterTask("Example", "/tmp/Example/Episode 1", false))
+}
@@ -4,6 +4,8 @@ import (
 	"PoDownloader/util"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 // Enclosure is the enclosure item of Podcast
@@ -28,6 +30,16 @@ func (e *Enclosure) GetEnclosureFileExtensionName(httpClient *http.Client) (stri
 	return extensionName, nil
 }
 
+// GetLength returns the enclosure length in bytes declared in the feed,
+// returns 0 if the length is absent or invalid
+func (e *Enclosure) GetLength() int64 {
+	length, err := strconv.ParseInt(strings.TrimSpace(e.Length), 10, 64)
+	if err != nil || length < 0 {
+		return 0
+	}
+	return length
+}
+
 // GetJSON returns an Enclosure instance in JSON format
 func (e *Enclosure) GetJSON() (string, error) {
 	jsonBytes, err := json.Marshal(e)
@@ -0,0 +1,14 @@
+package podcast
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEnclosure_GetLength(t *testing.T) {
+	assert.Equal(t, int64(12345), (&Enclosure{Length: "12345"}).GetLength())
+	assert.Equal(t, int64(12345), (&Enclosure{Length: " 12345 "}).GetLength())
+	assert.Equal(t, int64(0), (&Enclosure{Length: ""}).GetLength())
+	assert.Equal(t, int64(0), (&Enclosure{Length: "-1"}).GetLength())
+	assert.Equal(t, int64(0), (&Enclosure{Length: "foobar"}).GetLength())
+}
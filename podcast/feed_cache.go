@@ -0,0 +1,154 @@
+package podcast
+
+import (
+	"PoDownloader/util"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FeedCacheDirName is the directory name of the feed cache in the output directory
+const FeedCacheDirName = ".podownloader-feed-cache"
+
+// FeedCacheEntry is the cached HTTP validators and parse result of a feed
+type FeedCacheEntry struct {
+	URL string `json:"url"`
+	// ETag and LastModified are the validators of the first feed document, the pages it links are not revalidated
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+	// Complete is true if there was nothing to download when the podcast was planned after the feed was fetched,
+	// so that planning can be skipped while the feed is not modified
+	Complete bool `json:"complete"`
+	// PlanHash is the hash of the options the podcast was planned with when it was marked complete
+	PlanHash string `json:"planHash,omitempty"`
+	// Files are the absolute paths of the files planned for the podcast when it was marked complete
+	Files []string `json:"files,omitempty"`
+	// MaxPages is the Parser.MaxPages the feed was fetched with, 0 if only the first document was fetched
+	MaxPages int      `json:"maxPages,omitempty"`
+	Podcast  *Podcast `json:"podcast"`
+	// RawRSS is the content of the first feed document, it is saved as rss.xml when the feed is not modified
+	RawRSS string `json:"rawRSS,omitempty"`
+}
+
+// FeedCache stores a FeedCacheEntry file for each feed URL in a directory, it is safe for concurrent use
+// as long as a feed URL is not accessed concurrently
+type FeedCache struct {
+	dir string
+}
+
+// NewFeedCache returns a FeedCache instance that stores the entries in specified directory
+func NewFeedCache(dir string) *FeedCache {
+	return &FeedCache{dir: dir}
+}
+
+// entryPath returns the path of the entry file of specified feed URL
+func (c *FeedCache) entryPath(url string) string {
+	digest := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, hex.EncodeToString(digest[:])+".json")
+}
+
+// Get returns the cached entry of specified feed URL, returns nil if the feed is not cached
+func (c *FeedCache) Get(url string) *FeedCacheEntry {
+	content, err := os.ReadFile(c.entryPath(url))
+	if err != nil {
+		return nil
+	}
+	entry := &FeedCacheEntry{}
+	err = json.Unmarshal(content, entry)
+	if err != nil || entry.URL != url || entry.Podcast == nil {
+		return nil
+	}
+	return entry
+}
+
+// Put stores the entry of a feed URL atomically
+func (c *FeedCache) Put(entry *FeedCacheEntry) error {
+	err := util.EnsureDirAll(c.dir)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return util.WriteContentToFileAtomically(string(content), c.entryPath(entry.URL))
+}
+
+// IsComplete returns whether there was nothing to download when the cached feed of specified URL was planned
+// with the options of specified plan hash, and all the files planned then still exist
+func (c *FeedCache) IsComplete(url string, planHash string) bool {
+	entry := c.Get(url)
+	if entry == nil || !entry.Complete || entry.PlanHash != planHash {
+		return false
+	}
+	for _, file := range entry.Files {
+		if !util.IsFileExist(file) {
+			return false
+		}
+	}
+	return true
+}
+
+// MarkComplete records that there is nothing to download for the cached feed of specified URL
+// planned with the options of specified plan hash, files are the paths of the files planned for the podcast
+func (c *FeedCache) MarkComplete(url string, planHash string, files []string) error {
+	entry := c.Get(url)
+	if entry == nil {
+		return nil
+	}
+	entry.Complete = true
+	entry.PlanHash = planHash
+	entry.Files = make([]string, 0, len(files))
+	for _, file := range files {
+		absPath, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		entry.Files = append(entry.Files, absPath)
+	}
+	return c.Put(entry)
+}
+
+// ClearComplete clears FeedCacheEntry.Complete of the cached feeds whose planned files contain any of specified paths,
+// so that the podcasts are planned again next time, returns the number of cleared entries
+func (c *FeedCache) ClearComplete(paths []string) (int, error) {
+	pathSet := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return 0, err
+		}
+		pathSet[absPath] = true
+	}
+	entryFiles, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	cleared := 0
+	for _, entryFile := range entryFiles {
+		content, err := os.ReadFile(entryFile)
+		if err != nil {
+			continue
+		}
+		entry := &FeedCacheEntry{}
+		if json.Unmarshal(content, entry) != nil || !entry.Complete {
+			continue
+		}
+		for _, file := range entry.Files {
+			if pathSet[file] {
+				entry.Complete = false
+				err = c.Put(entry)
+				if err != nil {
+					return cleared, err
+				}
+				cleared++
+				break
+			}
+		}
+	}
+	return cleared, nil
+}
@@ -0,0 +1,98 @@
+package podcast
+
+import (
+	"PoDownloader/util"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+const testRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+<channel>
+<title>Example</title>
+<item>
+<title>Episode 1</title>
+<enclosure url="http://example.com/1.mp3" length="1024" type="audio/mpeg"/>
+</item>
+</channel>
+</rss>`
+
+func TestFeedCache(t *testing.T) {
+	feedCache := NewFeedCache(filepath.Join(t.TempDir(), FeedCacheDirName))
+	assert.Nil(t, feedCache.Get("http://example.com/rss.xml"))
+	assert.Nil(t, feedCache.Put(&FeedCacheEntry{URL: "http://example.com/rss.xml", ETag: "\"v1\"", Podcast: &Podcast{Title: "Example"}}))
+	entry := feedCache.Get("http://example.com/rss.xml")
+	assert.NotNil(t, entry)
+	assert.Equal(t, "\"v1\"", entry.ETag)
+	assert.Equal(t, "Example", entry.Podcast.Title)
+	assert.False(t, feedCache.IsComplete("http://example.com/rss.xml", "v1"))
+	assert.Nil(t, feedCache.MarkComplete("http://example.com/rss.xml", "v1", nil))
+	assert.True(t, feedCache.IsComplete("http://example.com/rss.xml", "v1"))
+	assert.False(t, feedCache.IsComplete("http://example.com/rss.xml", "v2"))
+	assert.Nil(t, feedCache.Get("http://example.com/other.xml"))
+}
+
+func TestFeedCache_CompleteFiles(t *testing.T) {
+	dir := t.TempDir()
+	feedCache := NewFeedCache(filepath.Join(dir, FeedCacheDirName))
+	episodePath := filepath.Join(dir, "Example", "1.mp3")
+	assert.Nil(t, util.EnsureDirAll(filepath.Dir(episodePath)))
+	assert.Nil(t, util.WriteContentToFileAtomically("1", episodePath))
+	assert.Nil(t, feedCache.Put(&FeedCacheEntry{URL: "http://example.com/rss.xml", Podcast: &Podcast{Title: "Example"}}))
+	assert.Nil(t, feedCache.Put(&FeedCacheEntry{URL: "http://example.com/other.xml", Podcast: &Podcast{Title: "Other"}}))
+	assert.Nil(t, feedCache.MarkComplete("http://example.com/rss.xml", "v1", []string{episodePath}))
+	assert.Nil(t, feedCache.MarkComplete("http://example.com/other.xml", "v1", nil))
+	assert.True(t, feedCache.IsComplete("http://example.com/rss.xml", "v1"))
+
+	cleared, err := feedCache.ClearComplete([]string{episodePath})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, cleared)
+	assert.False(t, feedCache.IsComplete("http://example.com/rss.xml", "v1"))
+	assert.True(t, feedCache.IsComplete("http://example.com/other.xml", "v1"))
+
+	assert.Nil(t, feedCache.MarkComplete("http://example.com/rss.xml", "v1", []string{episodePath}))
+	assert.Nil(t, os.Remove(episodePath))
+	assert.False(t, feedCache.IsComplete("http://example.com/rss.xml", "v1"))
+}
+
+func TestParser_ParsePodcastRSSConditional(t *testing.T) {
+	var notModifiedCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Header.Get("If-None-Match") == "\"v1\"" {
+			atomic.AddInt32(&notModifiedCount, 1)
+			writer.WriteHeader(http.StatusNotModified)
+			return
+		}
+		writer.Header().Set("ETag", "\"v1\"")
+		_, _ = writer.Write([]byte(testRSS))
+	}))
+	defer server.Close()
+	podcastParser := NewPodcastParser(&http.Client{}, "")
+	podcastParser.Cache = NewFeedCache(filepath.Join(t.TempDir(), FeedCacheDirName))
+
+	podcast, err := podcastParser.ParsePodcastRSS(server.URL)
+	assert.Nil(t, err)
+	assert.False(t, podcast.NotModified)
+	assert.Equal(t, testRSS, podcast.RawRSS)
+	assert.Equal(t, 1, podcast.GetItemCount())
+	podcastDownloadTask := podcast.GetPodcastDownloadTask(t.TempDir(), &http.Client{}, nil, nil)
+	assert.Nil(t, podcastDownloadTask.RSSDownloadTask)
+	assert.Equal(t, testRSS, podcastDownloadTask.RSSSaveTask.Text)
+
+	podcast, err = podcastParser.ParsePodcastRSS(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&notModifiedCount))
+	assert.True(t, podcast.NotModified)
+	assert.Equal(t, testRSS, podcast.RawRSS)
+	assert.Equal(t, "Example", podcast.Title)
+	assert.Equal(t, 1, podcast.GetItemCount())
+	assert.Equal(t, int64(1024), podcast.Items[0].Enclosures[0].GetLength())
+	podcastDownloadTask = podcast.GetPodcastDownloadTask(t.TempDir(), &http.Client{}, nil, nil)
+	assert.Nil(t, podcastDownloadTask.RSSDownloadTask)
+	assert.Equal(t, testRSS, podcastDownloadTask.RSSSaveTask.Text)
+}
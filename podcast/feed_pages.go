@@ -0,0 +1,118 @@
+package podcast
+
+import (
+	"github.com/mmcdole/gofeed"
+	"github.com/mmcdole/gofeed/atom"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// feedPageRelations are the link relations of RFC 5005 that are followed to traverse a feed,
+// "next" links a paged feed to its next page and "prev-archive" links an archived feed to its previous archive document
+var feedPageRelations = map[string]bool{
+	"next":         true,
+	"prev-archive": true,
+}
+
+// getFeedPageLinks returns the absolute URLs of the RFC 5005 paging and archive links in a feed document,
+// relative links are resolved against pageURL, the URL the document was fetched from
+func getFeedPageLinks(feed *gofeed.Feed, content string, pageURL *url.URL) []string {
+	var hrefs []string
+	if feed.FeedType == "atom" {
+		// gofeed.Feed drops the relations of the Atom links, so the document is parsed again by the Atom parser
+		atomFeed, err := (&atom.Parser{}).Parse(strings.NewReader(content))
+		if err != nil {
+			return nil
+		}
+		for _, link := range atomFeed.Links {
+			if feedPageRelations[link.Rel] {
+				hrefs = append(hrefs, link.Href)
+			}
+		}
+	} else {
+		// The atom:link elements of an RSS feed are kept in the extensions
+		for _, prefix := range []string{"atom", "atom10", "atom03"} {
+			for _, link := range feed.Extensions[prefix]["link"] {
+				if feedPageRelations[link.Attrs["rel"]] {
+					hrefs = append(hrefs, link.Attrs["href"])
+				}
+			}
+		}
+	}
+	var links []string
+	for _, href := range hrefs {
+		linkURL, err := url.Parse(strings.TrimSpace(href))
+		if err != nil || href == "" {
+			continue
+		}
+		links = append(links, pageURL.ResolveReference(linkURL).String())
+	}
+	return links
+}
+
+// fetchFeedPage downloads and parses a page of a paged or archived feed,
+// returns the parsed feed with the content and the final URL of the page
+func (p *Parser) fetchFeedPage(httpClient *http.Client, pageURL string) (*gofeed.Feed, string, *url.URL, error) {
+	req, err := http.NewRequest(http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if p.UserAgent != "" {
+		req.Header.Set("User-Agent", p.UserAgent)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	defer resp.Body.Close()
+	content, feed, err := readFeedDocument(pageURL, resp)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return feed, content, resp.Request.URL, nil
+}
+
+// mergeFeedPages follows the RFC 5005 paging and archive links of the first page of a feed breadth first,
+// and appends the items of the other pages to the first page. At most Parser.MaxPages pages are fetched,
+// a page is fetched only once even if the links form a loop, and the items whose GUID has been seen are skipped.
+// The traversal stops at a page that can not be fetched or parsed, the items of the fetched pages are kept
+func (p *Parser) mergeFeedPages(httpClient *http.Client, firstPage *gofeed.Feed, content string, pageURL *url.URL) {
+	visited := map[string]bool{pageURL.String(): true}
+	seenGUIDs := make(map[string]bool)
+	for _, item := range firstPage.Items {
+		if item.GUID != "" {
+			seenGUIDs[item.GUID] = true
+		}
+	}
+	pageCount := 1
+	queue := getFeedPageLinks(firstPage, content, pageURL)
+	for len(queue) > 0 && pageCount < p.MaxPages {
+		link := queue[0]
+		queue = queue[1:]
+		if visited[link] {
+			continue
+		}
+		visited[link] = true
+		page, pageContent, finalURL, err := p.fetchFeedPage(httpClient, link)
+		if err != nil {
+			return
+		}
+		pageCount++
+		if visited[finalURL.String()] && finalURL.String() != link {
+			// The link redirects to a page that has been merged
+			continue
+		}
+		visited[finalURL.String()] = true
+		for _, item := range page.Items {
+			if item.GUID != "" {
+				if seenGUIDs[item.GUID] {
+					continue
+				}
+				seenGUIDs[item.GUID] = true
+			}
+			firstPage.Items = append(firstPage.Items, item)
+		}
+		queue = append(queue, getFeedPageLinks(page, pageContent, finalURL)...)
+	}
+}
@@ -0,0 +1,116 @@
+package podcast
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newPagedFeedServer serves a paged RSS feed at /page/1 to /page/3 and an archive at /archive/1,
+// the last page links back to the first one to form a loop, and every page repeats the last item of the previous page
+func newPagedFeedServer(requestCount *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(requestCount, 1)
+		var links string
+		var guids []string
+		switch request.URL.Path {
+		case "/page/1":
+			links = `<atom:link rel="next" href="/page/2"/><atom:link rel="prev-archive" href="/archive/1"/>`
+			guids = []string{"1", "2"}
+		case "/page/2":
+			links = `<atom:link rel="next" href="3"/>`
+			guids = []string{"2", "3"}
+		case "/page/3":
+			links = `<atom:link rel="next" href="/page/1"/>`
+			guids = []string{"3", "4"}
+		case "/archive/1":
+			guids = []string{"5"}
+		default:
+			http.NotFound(writer, request)
+			return
+		}
+		var items strings.Builder
+		for _, guid := range guids {
+			items.WriteString(fmt.Sprintf("<item><title>Episode %s</title><guid>%s</guid></item>", guid, guid))
+		}
+		_, _ = writer.Write([]byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel><title>Example</title>%s%s</channel></rss>`, links, items.String())))
+	}))
+}
+
+func getItemGUIDs(podcast *Podcast) []string {
+	var guids []string
+	for _, item := range podcast.Items {
+		guids = append(guids, item.GUID)
+	}
+	return guids
+}
+
+func TestParser_ParsePodcastRSSPages(t *testing.T) {
+	var requestCount int32
+	server := newPagedFeedServer(&requestCount)
+	defer server.Close()
+	parser := NewPodcastParser(&http.Client{}, "")
+
+	podcast, err := parser.ParsePodcastRSS(server.URL + "/page/1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, getItemGUIDs(podcast))
+	assert.Equal(t, int32(1), requestCount)
+
+	parser.MaxPages = 10
+	requestCount = 0
+	podcast, err = parser.ParsePodcastRSS(server.URL + "/page/1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2", "3", "5", "4"}, getItemGUIDs(podcast))
+	// The loop back to the first page is not followed
+	assert.Equal(t, int32(4), requestCount)
+	assert.Contains(t, podcast.RawRSS, "/archive/1")
+
+	parser.MaxPages = 2
+	podcast, err = parser.ParsePodcastRSS(server.URL + "/page/1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, getItemGUIDs(podcast))
+}
+
+func TestParser_ParsePodcastRSSPagesCache(t *testing.T) {
+	var requestCount int32
+	server := newPagedFeedServer(&requestCount)
+	defer server.Close()
+	parser := NewPodcastParser(&http.Client{}, "")
+	parser.Cache = NewFeedCache(filepath.Join(t.TempDir(), FeedCacheDirName))
+
+	_, err := parser.ParsePodcastRSS(server.URL + "/page/1")
+	assert.Nil(t, err)
+	// The feed cached with the first page only is fetched in full when more pages are requested
+	parser.MaxPages = 10
+	podcast, err := parser.ParsePodcastRSS(server.URL + "/page/1")
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(podcast.Items))
+	assert.Equal(t, 10, parser.Cache.Get(server.URL+"/page/1").MaxPages)
+}
+
+func TestGetFeedPageLinks_Atom(t *testing.T) {
+	var requestCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&requestCount, 1)
+		next := ""
+		if request.URL.Path == "/feed" {
+			next = `<link rel="next" href="/feed?page=2"/>`
+		}
+		_, _ = writer.Write([]byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Example</title><link rel="self" href="/feed"/>%s
+<entry><title>Episode</title><id>%s</id></entry></feed>`, next, request.URL.String())))
+	}))
+	defer server.Close()
+	parser := NewPodcastParser(&http.Client{}, "")
+	parser.MaxPages = 10
+	podcast, err := parser.ParsePodcastRSS(server.URL + "/feed")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/feed", "/feed?page=2"}, getItemGUIDs(podcast))
+	assert.Equal(t, int32(2), requestCount)
+}
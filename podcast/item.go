@@ -14,7 +14,11 @@ type Item struct {
 	PubDate     *time.Time           `json:"pubDate,omitempty"`
 	GUID        string               `json:"guid,omitempty"`
 	ITunesExt   *ITunesItemExtension `json:"iTunesExt,omitempty"`
-	Enclosures  []*Enclosure         `json:"enclosures,omitempty"`
+	// PodcastExt is the Podcasting 2.0 namespace fields, nil if the item does not contain them
+	PodcastExt *PodcastItemExtension `json:"podcastExt,omitempty"`
+	// SimpleChapters are the Podlove Simple Chapters of the item
+	SimpleChapters []*SimpleChapter `json:"simpleChapters,omitempty"`
+	Enclosures     []*Enclosure     `json:"enclosures,omitempty"`
 }
 
 // ITunesItemExtension is the extension fields of Podcast items
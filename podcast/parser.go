@@ -1,6 +1,7 @@
 package podcast
 
 import (
+	podownloader "PoDownloader"
 	"PoDownloader/util"
 	"errors"
 	"github.com/mmcdole/gofeed"
@@ -10,11 +11,21 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Parser is used to parse podcasts
 type Parser struct {
 	*gofeed.Parser
+	// Cache stores the HTTP validators and parse results of the feeds, nil if the feeds are not cached
+	Cache *FeedCache
+	// ProgressMode determines whether the progress bar of parsing feeds is displayed
+	ProgressMode podownloader.ProgressMode
+	// Events receives an EventFeedParsed event for each parsed feed, nil if the events are not emitted
+	Events podownloader.EventSink
+	// MaxPages is the maximum number of documents fetched for a feed by following its RFC 5005 paging and archive links,
+	// the items of all documents are merged into the podcast. 0 or 1 reads the first document only
+	MaxPages int
 }
 
 // NewPodcastParser initializes and returns a Parser instance
@@ -22,34 +33,126 @@ func NewPodcastParser(httpClient *http.Client, userAgent string) *Parser {
 	rssParser := gofeed.NewParser()
 	rssParser.Client = httpClient
 	rssParser.UserAgent = userAgent
-	return &Parser{rssParser}
+	return &Parser{Parser: rssParser, ProgressMode: podownloader.ProgressModeBar}
 }
 
-// ParsePodcastRSS returns a Podcast instance that parsed from specified RSS link
+// ParsePodcastRSS returns a Podcast instance that parsed from specified RSS link,
+// the older pages and archives of the feed are merged if Parser.MaxPages is greater than 1.
+// If the feed is cached in Parser.Cache, a conditional request is sent and the cached Podcast is returned
+// with Podcast.NotModified set when the server responds 304 Not Modified.
+// A *podownloader.FeedError is returned if the feed can not be fetched or parsed
 func (p *Parser) ParsePodcastRSS(RSS string) (*Podcast, error) {
-	feed, err := p.ParseURL(RSS)
+	httpClient := p.Client
+	if httpClient == nil {
+		return nil, errors.New("failed to get http client")
+	}
+	var cacheEntry *FeedCacheEntry
+	if p.Cache != nil {
+		cacheEntry = p.Cache.Get(RSS)
+		if cacheEntry != nil && p.MaxPages > 1 && cacheEntry.MaxPages < p.MaxPages {
+			// The cached podcast misses the pages beyond the limit it was fetched with, the feed is fetched in full
+			cacheEntry = nil
+		}
+	}
+	req, err := http.NewRequest(http.MethodGet, RSS, nil)
 	if err != nil {
-		httpClient := p.Client
-		if httpClient == nil {
-			return nil, errors.New("failed to get http client")
+		return nil, newFeedError(RSS, nil, podownloader.FeedStageFetch, err)
+	}
+	if p.UserAgent != "" {
+		req.Header.Set("User-Agent", p.UserAgent)
+	}
+	if cacheEntry != nil {
+		if cacheEntry.ETag != "" {
+			req.Header.Set("If-None-Match", cacheEntry.ETag)
 		}
-
-		resp, err := httpClient.Get(RSS)
-		if err != nil {
-			return nil, err
+		if cacheEntry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cacheEntry.LastModified)
 		}
-		defer resp.Body.Close()
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, newFeedError(RSS, nil, podownloader.FeedStageFetch, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && cacheEntry != nil {
+		podcast := cacheEntry.Podcast
+		podcast.NotModified = true
+		// The cached content is saved as rss.xml so that the feed is not downloaded again
+		podcast.RawRSS = cacheEntry.RawRSS
+		return podcast, nil
+	}
+	respBody, feed, err := readFeedDocument(RSS, resp)
+	if err != nil {
+		return nil, err
+	}
+	if p.MaxPages > 1 {
+		p.mergeFeedPages(httpClient, feed, respBody, resp.Request.URL)
+	}
+	podcast := newPodcastFromFeed(RSS, feed)
+	if p.Cache != nil {
+		// The podcast can still be downloaded if the feed can not be cached, it will be fetched in full next time
+		_ = p.Cache.Put(&FeedCacheEntry{
+			URL:          RSS,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			FetchedAt:    time.Now(),
+			MaxPages:     p.MaxPages,
+			Podcast:      podcast,
+			RawRSS:       respBody,
+		})
+	}
+	podcast.RawRSS = respBody
+	return podcast, nil
+}
 
-		respBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
+// newFeedError returns a FeedError of specified feed URL at specified stage,
+// FeedError.FinalURL is set if the request of the response was redirected, resp is nil if there is no response
+func newFeedError(feedURL string, resp *http.Response, stage podownloader.FeedStage, err error) *podownloader.FeedError {
+	feedErr := &podownloader.FeedError{
+		URL:   feedURL,
+		Stage: stage,
+		Err:   err,
+	}
+	if resp != nil && resp.Request != nil && resp.Request.URL.String() != feedURL {
+		feedErr.FinalURL = resp.Request.URL.String()
+	}
+	return feedErr
+}
 
-		feed, err = p.ParseString(util.StripInvalidXmlCharacter(string(respBody)))
+// readFeedDocument reads and parses the feed document in the response of specified feed URL,
+// returns the content of the document with the parsed feed, or a FeedError
+func readFeedDocument(feedURL string, resp *http.Response) (string, *gofeed.Feed, error) {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		statusErr := &podownloader.HTTPStatusError{
+			URL:        feedURL,
+			StatusCode: resp.StatusCode,
+			RetryAfter: util.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+		return "", nil, newFeedError(feedURL, resp, podownloader.FeedStageHTTPStatus, statusErr)
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, newFeedError(feedURL, resp, podownloader.FeedStageFetch, err)
+	}
+	content := string(respBody)
+	if strings.TrimSpace(content) == "" {
+		return "", nil, newFeedError(feedURL, resp, podownloader.FeedStageEmpty, nil)
+	}
+	// The embedded gofeed.Parser is not safe for concurrent use, feeds are parsed concurrently with separate parsers
+	feedParser := gofeed.NewParser()
+	feed, err := feedParser.ParseString(content)
+	if err != nil {
+		feed, err = feedParser.ParseString(util.StripInvalidXmlCharacter(content))
 		if err != nil {
-			return nil, err
+			return "", nil, newFeedError(feedURL, resp, podownloader.FeedStageParse, err)
 		}
 	}
+	normalizePodcastNamespace(feed, content)
+	return content, feed, nil
+}
+
+// newPodcastFromFeed converts a parsed feed of specified RSS link to a Podcast instance
+func newPodcastFromFeed(RSS string, feed *gofeed.Feed) *Podcast {
 	var podcastCategories []*Category
 	var iTunesExt *ITunesFeedExtension
 	if feed.ITunesExt != nil {
@@ -86,12 +189,14 @@ func (p *Parser) ParsePodcastRSS(RSS string) (*Podcast, error) {
 			})
 		}
 		newPodcastItem := &Item{
-			Title:       strings.TrimSpace(item.Title),
-			SafeTitle:   util.SanitizeFileName(strings.TrimSpace(item.Title)),
-			Description: item.Description,
-			PubDate:     item.PublishedParsed,
-			GUID:        item.GUID,
-			Enclosures:  enclosures,
+			Title:          strings.TrimSpace(item.Title),
+			SafeTitle:      util.SanitizeFileName(strings.TrimSpace(item.Title)),
+			Description:    item.Description,
+			PubDate:        item.PublishedParsed,
+			GUID:           item.GUID,
+			PodcastExt:     newPodcastItemExtension(item.Extensions),
+			SimpleChapters: newSimpleChapters(item.Extensions),
+			Enclosures:     enclosures,
 		}
 		if item.ITunesExt != nil {
 			newPodcastItem.ITunesExt = &ITunesItemExtension{
@@ -110,19 +215,49 @@ func (p *Parser) ParsePodcastRSS(RSS string) (*Podcast, error) {
 		SafeTitle:   util.SanitizeFileName(strings.TrimSpace(feed.Title)),
 		Description: feed.Description,
 		ITunesExt:   iTunesExt,
+		PodcastExt:  newPodcastFeedExtension(feed.Extensions),
 		Items:       podcastItems,
-	}, nil
+	}
 }
 
-// ParsePodcastsFromRSSListWithProgress returns Podcast
-func (p *Parser) ParsePodcastsFromRSSListWithProgress(rssList []string) ([]*Podcast, []string) {
+// parseFeed parses specified RSS link and returns the parsed podcast with the parse result
+func (p *Parser) parseFeed(rss string) (*Podcast, *podownloader.FeedResult) {
+	startTime := time.Now()
+	podcast, err := p.ParsePodcastRSS(rss)
+	feedResult := &podownloader.FeedResult{
+		URL:      rss,
+		Duration: time.Since(startTime),
+		Err:      err,
+	}
+	if err == nil {
+		feedResult.Title = podcast.Title
+		feedResult.NotModified = podcast.NotModified
+		feedResult.Items = podcast.GetItemCount()
+	}
+	if p.Events != nil {
+		p.Events.Emit(podownloader.NewFeedParsedEvent(feedResult))
+	}
+	return podcast, feedResult
+}
+
+// ParsePodcastsFromRSSListWithProgress parses specified RSS links with threadCount goroutines,
+// returns the parsed podcasts and the parse result of every RSS link, both in the order of rssList
+func (p *Parser) ParsePodcastsFromRSSListWithProgress(rssList []string, threadCount int) ([]*Podcast, []*podownloader.FeedResult) {
+	if threadCount > len(rssList) {
+		threadCount = len(rssList)
+	}
+	if threadCount < 1 {
+		threadCount = 1
+	}
 	var (
-		podcasts []*Podcast
-		failed   []string
+		// Each goroutine writes the results of an RSS link to its index, so the order of rssList is kept
+		parsedPodcasts = make([]*Podcast, len(rssList))
+		feedResults    = make([]*podownloader.FeedResult, len(rssList))
+		indexChan      = make(chan int)
 	)
 	downWg := new(sync.WaitGroup)
-	downWg.Add(1)
-	progressBar := mpb.New(mpb.WithWaitGroup(downWg))
+	downWg.Add(threadCount)
+	progressBar := podownloader.NewProgress(p.ProgressMode, mpb.WithWaitGroup(downWg))
 	task := "[Parse Podcast RSS]"
 	bar := progressBar.AddBar(
 		int64(len(rssList)),
@@ -132,18 +267,26 @@ func (p *Parser) ParsePodcastsFromRSSListWithProgress(rssList []string) ([]*Podc
 		),
 		mpb.AppendDecorators(decor.Percentage(decor.WC{W: 5})),
 	)
-	go func() {
-		defer downWg.Done()
-		for _, rss := range rssList {
-			podcast, err := p.ParsePodcastRSS(rss)
-			if err != nil {
-				failed = append(failed, rss)
-			} else {
-				podcasts = append(podcasts, podcast)
+	for i := 0; i < threadCount; i++ {
+		go func() {
+			defer downWg.Done()
+			for index := range indexChan {
+				parsedPodcasts[index], feedResults[index] = p.parseFeed(rssList[index])
+				bar.Increment()
 			}
-			bar.IncrBy(1)
-		}
-	}()
+		}()
+	}
+	for index := range rssList {
+		indexChan <- index
+	}
+	close(indexChan)
 	progressBar.Wait()
-	return podcasts, failed
+
+	var podcasts []*Podcast
+	for _, podcast := range parsedPodcasts {
+		if podcast != nil {
+			podcasts = append(podcasts, podcast)
+		}
+	}
+	return podcasts, feedResults
 }
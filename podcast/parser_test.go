@@ -1,12 +1,103 @@
 package podcast
 
 import (
+	podownloader "PoDownloader"
+	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestNewPodcastParser(t *testing.T) {
-	podcastParser := NewPodcastParser(&http.Client{})
+	podcastParser := NewPodcastParser(&http.Client{}, "")
 	assert.NotNil(t, podcastParser)
 }
+
+func TestParser_ParsePodcastsFromRSSListWithProgress(t *testing.T) {
+	var running, maxRunning int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			maxCurrent := atomic.LoadInt32(&maxRunning)
+			if current <= maxCurrent || atomic.CompareAndSwapInt32(&maxRunning, maxCurrent, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		if request.URL.Path == "/broken" {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = writer.Write([]byte(strings.Replace(testRSS, "<title>Example</title>", fmt.Sprintf("<title>%s</title>", request.URL.Path), 1)))
+	}))
+	defer server.Close()
+	podcastParser := NewPodcastParser(&http.Client{}, "")
+	podcastParser.ProgressMode = podownloader.ProgressModePlain
+	rssList := []string{server.URL + "/a", server.URL + "/b", server.URL + "/broken", server.URL + "/c", server.URL + "/d"}
+
+	podcasts, feedResults := podcastParser.ParsePodcastsFromRSSListWithProgress(rssList, 3)
+	assert.Greater(t, atomic.LoadInt32(&maxRunning), int32(1))
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxRunning), int32(3))
+	assert.Equal(t, len(rssList), len(feedResults))
+	for index, feedResult := range feedResults {
+		assert.Equal(t, rssList[index], feedResult.URL)
+	}
+	assert.NotNil(t, feedResults[2].Err)
+	var titles []string
+	for _, podcast := range podcasts {
+		titles = append(titles, podcast.Title)
+	}
+	assert.Equal(t, []string{"/a", "/b", "/c", "/d"}, titles)
+}
+
+func TestParser_ParsePodcastRSSFeedError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/moved.xml":
+			http.Redirect(writer, request, "/missing.xml", http.StatusMovedPermanently)
+		case "/empty.xml":
+			_, _ = writer.Write([]byte("  \n"))
+		case "/page.html":
+			_, _ = writer.Write([]byte("<html><body>Not a feed</body></html>"))
+		case "/busy.xml":
+			writer.Header().Set("Retry-After", "120")
+			writer.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			http.NotFound(writer, request)
+		}
+	}))
+	defer server.Close()
+	parser := NewPodcastParser(&http.Client{}, "")
+
+	_, err := parser.ParsePodcastRSS(server.URL + "/moved.xml")
+	feedErr := podownloader.GetFeedError(err)
+	assert.NotNil(t, feedErr)
+	assert.Equal(t, podownloader.FeedStageHTTPStatus, feedErr.Stage)
+	assert.Equal(t, server.URL+"/moved.xml", feedErr.URL)
+	assert.Equal(t, server.URL+"/missing.xml", feedErr.FinalURL)
+	assert.Equal(t, podownloader.ErrorClassHTTPStatus, podownloader.ClassifyError(err))
+
+	_, err = parser.ParsePodcastRSS(server.URL + "/busy.xml")
+	var statusErr *podownloader.HTTPStatusError
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+	assert.Equal(t, 120*time.Second, statusErr.RetryAfter)
+
+	_, err = parser.ParsePodcastRSS(server.URL + "/empty.xml")
+	assert.Equal(t, podownloader.FeedStageEmpty, podownloader.GetFeedError(err).Stage)
+	_, err = parser.ParsePodcastRSS(server.URL + "/page.html")
+	assert.Equal(t, podownloader.FeedStageParse, podownloader.GetFeedError(err).Stage)
+	assert.Equal(t, "", podownloader.GetFeedError(err).FinalURL)
+
+	server.Close()
+	podcasts, feedResults := parser.ParsePodcastsFromRSSListWithProgress([]string{server.URL + "/rss.xml"}, 1)
+	assert.Empty(t, podcasts)
+	assert.Equal(t, podownloader.FeedStageFetch, feedResults[0].FeedError().Stage)
+	assert.Equal(t, podownloader.ErrorClassNetwork, feedResults[0].ErrorClass())
+}
@@ -17,7 +17,13 @@ type Podcast struct {
 	SafeTitle   string               `json:"safeTitle,omitempty"`
 	Description string               `json:"description,omitempty"`
 	ITunesExt   *ITunesFeedExtension `json:"iTunesExt,omitempty"`
-	Items       []*Item              `json:"items,omitempty"`
+	// PodcastExt is the Podcasting 2.0 namespace fields, nil if the feed does not contain them
+	PodcastExt *PodcastFeedExtension `json:"podcastExt,omitempty"`
+	Items      []*Item               `json:"items,omitempty"`
+	// RawRSS is the content of the first feed document, it is the cached content if the feed is not modified
+	RawRSS string `json:"-"`
+	// NotModified is true if the feed is not modified since it was cached
+	NotModified bool `json:"-"`
 }
 
 // ITunesFeedExtension is the extension fields of Podcast
@@ -37,6 +43,23 @@ type ITunesOwner struct {
 	Name  string `json:"name,omitempty"`
 }
 
+// TaskOptions contains the options that control which download tasks are created from a Podcast
+type TaskOptions struct {
+	// TranscriptFormats are the formats of the podcast:transcript links to download, empty means no transcripts
+	TranscriptFormats []string
+	// ShownotesChapters enables extracting the chapters from the timestamp lines in the shownotes
+	// when the item has neither podcast:chapters nor psc:chapters
+	ShownotesChapters bool
+}
+
+// DefaultTaskOptions returns the default task options
+func DefaultTaskOptions() *TaskOptions {
+	return &TaskOptions{
+		TranscriptFormats: AllTranscriptFormats,
+		ShownotesChapters: false,
+	}
+}
+
 // GetItemCount returns the number of items in a Podcast instance
 func (p *Podcast) GetItemCount() int {
 	return len(p.Items)
@@ -48,8 +71,12 @@ func (p *Podcast) GetPodcastDownloadDestDir(destDir string) string {
 	return path.Join(destDir, p.SafeTitle)
 }
 
-// GetPodcastDownloadTask returns a podownloader.PodcastDownloadTask instance from a Podcast instance
-func (p *Podcast) GetPodcastDownloadTask(destDir string, httpClient *http.Client, logger *logger.Logger) *podownloader.PodcastDownloadTask {
+// GetPodcastDownloadTask returns a podownloader.PodcastDownloadTask instance from a Podcast instance,
+// DefaultTaskOptions is used if options is nil
+func (p *Podcast) GetPodcastDownloadTask(destDir string, httpClient *http.Client, logger *logger.Logger, options *TaskOptions) *podownloader.PodcastDownloadTask {
+	if options == nil {
+		options = DefaultTaskOptions()
+	}
 	podcastDownloadDestDir := p.GetPodcastDownloadDestDir(destDir)
 
 	// Podcast cover download task
@@ -62,7 +89,7 @@ func (p *Podcast) GetPodcastDownloadTask(destDir string, httpClient *http.Client
 		podcastCoverDownloadDest := path.Join(podcastDownloadDestDir, fmt.Sprintf("cover.%s", podcastCoverExtensionName))
 		podcastCoverDownloadTask = &podownloader.URLDownloadTask{
 			JobName: p.Title,
-			JobType: "Cover",
+			JobType: podownloader.JobTypeCover,
 			URL:     p.ITunesExt.Image,
 			Dest:    podcastCoverDownloadDest,
 		}
@@ -83,7 +110,7 @@ func (p *Podcast) GetPodcastDownloadTask(destDir string, httpClient *http.Client
 			} else {
 				episodeCoverDownloadTask = &podownloader.URLDownloadTask{
 					JobName: fmt.Sprintf("%s - %s", p.Title, item.Title),
-					JobType: "Cover",
+					JobType: podownloader.JobTypeCover,
 					URL:     item.ITunesExt.Image,
 					Dest:    path.Join(itemDownloadDestDir, fmt.Sprintf("cover.%s", episodeCoverExtensionName)),
 				}
@@ -95,7 +122,7 @@ func (p *Podcast) GetPodcastDownloadTask(destDir string, httpClient *http.Client
 		if item.Description != "" {
 			shownoteDownloadTask = &podownloader.TextSaveTask{
 				JobName: fmt.Sprintf("%s - %s", p.Title, item.Title),
-				JobType: "Shownotes",
+				JobType: podownloader.JobTypeShownotes,
 				Text:    item.Description,
 				Dest:    path.Join(itemDownloadDestDir, "shownotes.html"),
 			}
@@ -123,34 +150,52 @@ func (p *Podcast) GetPodcastDownloadTask(destDir string, httpClient *http.Client
 				}
 				enclosureDownloadTasks = append(enclosureDownloadTasks, &podownloader.URLDownloadTask{
 					JobName: jobName,
-					JobType: "Enclosure",
+					JobType: podownloader.JobTypeEnclosure,
 					URL:     enclosure.URL,
 					Dest:    path.Join(itemDownloadDestDir, enclosureFileName),
+					Length:  enclosure.GetLength(),
 				})
 			}
 		}
 
 		episodeDownloadTasks = append(episodeDownloadTasks, &podownloader.EpisodeDownloadTask{
-			EpisodeTitle:           item.Title,
-			BaseDestDir:            itemDownloadDestDir,
-			EnclosureDownloadTasks: enclosureDownloadTasks,
-			CoverDownloadTask:      episodeCoverDownloadTask,
-			ShownotesDownloadTask:  shownoteDownloadTask,
+			EpisodeTitle:            item.Title,
+			PubDate:                 item.PubDate,
+			BaseDestDir:             itemDownloadDestDir,
+			EnclosureDownloadTasks:  enclosureDownloadTasks,
+			CoverDownloadTask:       episodeCoverDownloadTask,
+			ShownotesDownloadTask:   shownoteDownloadTask,
+			TranscriptDownloadTasks: item.GetTranscriptDownloadTasks(p.Title, itemDownloadDestDir, options.TranscriptFormats),
+			ChapterTask:             item.GetChapterTask(p.Title, itemDownloadDestDir, options.ShownotesChapters),
 		})
 	}
 
-	return &podownloader.PodcastDownloadTask{
+	podcastDownloadTask := &podownloader.PodcastDownloadTask{
+		RSS:                  p.RSS,
 		PodcastTitle:         p.Title,
 		BaseDestDir:          podcastDownloadDestDir,
 		EpisodeDownloadTasks: episodeDownloadTasks,
 		CoverDownloadTask:    podcastCoverDownloadTask,
-		RSSDownloadTask: &podownloader.URLDownloadTask{
-			JobName: fmt.Sprintf("%s | RSS", p.Title),
-			JobType: "RSS",
+	}
+	// RSS download task, the fetched feed content is saved directly instead of downloading the feed again
+	rssJobName := fmt.Sprintf("%s | RSS", p.Title)
+	rssDownloadDest := path.Join(podcastDownloadDestDir, "rss.xml")
+	if p.RawRSS != "" {
+		podcastDownloadTask.RSSSaveTask = &podownloader.TextSaveTask{
+			JobName: rssJobName,
+			JobType: podownloader.JobTypeRSS,
+			Text:    p.RawRSS,
+			Dest:    rssDownloadDest,
+		}
+	} else {
+		podcastDownloadTask.RSSDownloadTask = &podownloader.URLDownloadTask{
+			JobName: rssJobName,
+			JobType: podownloader.JobTypeRSS,
 			URL:     p.RSS,
-			Dest:    path.Join(podcastDownloadDestDir, "rss.xml"),
-		},
+			Dest:    rssDownloadDest,
+		}
 	}
+	return podcastDownloadTask
 }
 
 // GetJSON returns a Podcast instance JSON format
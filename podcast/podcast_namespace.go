@@ -0,0 +1,346 @@
+package podcast
+
+import (
+	"encoding/xml"
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// podcastNamespacePrefix is the key of the Podcasting 2.0 namespace in the extensions after normalizePodcastNamespace
+const podcastNamespacePrefix = "podcast"
+
+// podcastNamespaceURIs are the current URI of the Podcasting 2.0 namespace and
+// the URI of the early versions of the specification that is still declared by some feeds
+var podcastNamespaceURIs = map[string]bool{
+	"https://podcastindex.org/namespace/1.0":                                      true,
+	"https://github.com/Podcastindex-org/podcast-namespace/blob/main/docs/1.0.md": true,
+}
+
+// PodcastFeedExtension is the Podcasting 2.0 namespace fields of Podcast
+type PodcastFeedExtension struct {
+	GUID     string     `json:"guid,omitempty"`
+	Persons  []*Person  `json:"persons,omitempty"`
+	Funding  []*Funding `json:"funding,omitempty"`
+	Location *Location  `json:"location,omitempty"`
+	License  *License   `json:"license,omitempty"`
+}
+
+// PodcastItemExtension is the Podcasting 2.0 namespace fields of Podcast items
+type PodcastItemExtension struct {
+	Transcripts         []*Transcript         `json:"transcripts,omitempty"`
+	Chapters            *Chapters             `json:"chapters,omitempty"`
+	Persons             []*Person             `json:"persons,omitempty"`
+	Soundbites          []*Soundbite          `json:"soundbites,omitempty"`
+	Season              *Season               `json:"season,omitempty"`
+	Episode             *Episode              `json:"episode,omitempty"`
+	Location            *Location             `json:"location,omitempty"`
+	License             *License              `json:"license,omitempty"`
+	AlternateEnclosures []*AlternateEnclosure `json:"alternateEnclosures,omitempty"`
+}
+
+// Transcript is the podcast:transcript element of an item
+type Transcript struct {
+	URL      string `json:"url,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Language string `json:"language,omitempty"`
+	Rel      string `json:"rel,omitempty"`
+}
+
+// Chapters is the podcast:chapters element of an item
+type Chapters struct {
+	URL  string `json:"url,omitempty"`
+	Type string `json:"type,omitempty"`
+}
+
+// Person is the podcast:person element of a podcast or an item
+type Person struct {
+	Name  string `json:"name,omitempty"`
+	Role  string `json:"role,omitempty"`
+	Group string `json:"group,omitempty"`
+	Image string `json:"image,omitempty"`
+	Href  string `json:"href,omitempty"`
+}
+
+// Funding is the podcast:funding element of a podcast
+type Funding struct {
+	URL  string `json:"url,omitempty"`
+	Text string `json:"text,omitempty"`
+}
+
+// Soundbite is the podcast:soundbite element of an item, StartTime and Duration are in seconds
+type Soundbite struct {
+	StartTime float64 `json:"startTime"`
+	Duration  float64 `json:"duration"`
+	Title     string  `json:"title,omitempty"`
+}
+
+// Season is the podcast:season element of an item
+type Season struct {
+	Number int    `json:"number"`
+	Name   string `json:"name,omitempty"`
+}
+
+// Episode is the podcast:episode element of an item, the episode number can be a decimal
+type Episode struct {
+	Number  float64 `json:"number"`
+	Display string  `json:"display,omitempty"`
+}
+
+// Location is the podcast:location element of a podcast or an item
+type Location struct {
+	Name string `json:"name,omitempty"`
+	Geo  string `json:"geo,omitempty"`
+	OSM  string `json:"osm,omitempty"`
+}
+
+// License is the podcast:license element of a podcast or an item
+type License struct {
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+// AlternateEnclosure is the podcast:alternateEnclosure element of an item
+type AlternateEnclosure struct {
+	Type      string                      `json:"type,omitempty"`
+	Length    int64                       `json:"length,omitempty"`
+	Bitrate   float64                     `json:"bitrate,omitempty"`
+	Height    int                         `json:"height,omitempty"`
+	Language  string                      `json:"language,omitempty"`
+	Title     string                      `json:"title,omitempty"`
+	Rel       string                      `json:"rel,omitempty"`
+	Codecs    string                      `json:"codecs,omitempty"`
+	Default   bool                        `json:"default,omitempty"`
+	Sources   []*AlternateEnclosureSource `json:"sources,omitempty"`
+	Integrity *Integrity                  `json:"integrity,omitempty"`
+}
+
+// AlternateEnclosureSource is the podcast:source element of an AlternateEnclosure
+type AlternateEnclosureSource struct {
+	URI         string `json:"uri,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+}
+
+// Integrity is the podcast:integrity element of an AlternateEnclosure
+type Integrity struct {
+	Type  string `json:"type,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// parseInt returns the integer of specified text, returns 0 if the text is not an integer
+func parseInt(text string) int64 {
+	value, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// parseFloat returns the number of specified text, returns 0 if the text is not a number
+func parseFloat(text string) float64 {
+	value, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// newPerson converts a podcast:person extension to a Person instance
+func newPerson(extension ext.Extension) *Person {
+	return &Person{
+		Name:  extension.Value,
+		Role:  extension.Attrs["role"],
+		Group: extension.Attrs["group"],
+		Image: extension.Attrs["img"],
+		Href:  extension.Attrs["href"],
+	}
+}
+
+// newLocation converts the first podcast:location extension to a Location instance, returns nil if there is none
+func newLocation(extensions []ext.Extension) *Location {
+	if len(extensions) == 0 {
+		return nil
+	}
+	return &Location{
+		Name: extensions[0].Value,
+		Geo:  extensions[0].Attrs["geo"],
+		OSM:  extensions[0].Attrs["osm"],
+	}
+}
+
+// newLicense converts the first podcast:license extension to a License instance, returns nil if there is none
+func newLicense(extensions []ext.Extension) *License {
+	if len(extensions) == 0 {
+		return nil
+	}
+	return &License{
+		Name: extensions[0].Value,
+		URL:  extensions[0].Attrs["url"],
+	}
+}
+
+// newAlternateEnclosure converts a podcast:alternateEnclosure extension to an AlternateEnclosure instance
+func newAlternateEnclosure(extension ext.Extension) *AlternateEnclosure {
+	alternateEnclosure := &AlternateEnclosure{
+		Type:     extension.Attrs["type"],
+		Length:   parseInt(extension.Attrs["length"]),
+		Bitrate:  parseFloat(extension.Attrs["bitrate"]),
+		Height:   int(parseInt(extension.Attrs["height"])),
+		Language: extension.Attrs["lang"],
+		Title:    extension.Attrs["title"],
+		Rel:      extension.Attrs["rel"],
+		Codecs:   extension.Attrs["codecs"],
+		Default:  extension.Attrs["default"] == "true",
+	}
+	for _, source := range extension.Children["source"] {
+		alternateEnclosure.Sources = append(alternateEnclosure.Sources, &AlternateEnclosureSource{
+			URI:         source.Attrs["uri"],
+			ContentType: source.Attrs["contentType"],
+		})
+	}
+	if integrity := extension.Children["integrity"]; len(integrity) > 0 {
+		alternateEnclosure.Integrity = &Integrity{
+			Type:  integrity[0].Attrs["type"],
+			Value: integrity[0].Attrs["value"],
+		}
+	}
+	return alternateEnclosure
+}
+
+// newPodcastFeedExtension returns the PodcastFeedExtension parsed from the extensions of a feed,
+// returns nil if the feed does not contain Podcasting 2.0 namespace elements
+func newPodcastFeedExtension(extensions ext.Extensions) *PodcastFeedExtension {
+	elements, ok := extensions[podcastNamespacePrefix]
+	if !ok {
+		return nil
+	}
+	feedExt := &PodcastFeedExtension{
+		Location: newLocation(elements["location"]),
+		License:  newLicense(elements["license"]),
+	}
+	if guid := elements["guid"]; len(guid) > 0 {
+		feedExt.GUID = guid[0].Value
+	}
+	for _, person := range elements["person"] {
+		feedExt.Persons = append(feedExt.Persons, newPerson(person))
+	}
+	for _, funding := range elements["funding"] {
+		feedExt.Funding = append(feedExt.Funding, &Funding{
+			URL:  funding.Attrs["url"],
+			Text: funding.Value,
+		})
+	}
+	return feedExt
+}
+
+// newPodcastItemExtension returns the PodcastItemExtension parsed from the extensions of a feed item,
+// returns nil if the item does not contain Podcasting 2.0 namespace elements
+func newPodcastItemExtension(extensions ext.Extensions) *PodcastItemExtension {
+	elements, ok := extensions[podcastNamespacePrefix]
+	if !ok {
+		return nil
+	}
+	itemExt := &PodcastItemExtension{
+		Location: newLocation(elements["location"]),
+		License:  newLicense(elements["license"]),
+	}
+	for _, transcript := range elements["transcript"] {
+		itemExt.Transcripts = append(itemExt.Transcripts, &Transcript{
+			URL:      transcript.Attrs["url"],
+			Type:     transcript.Attrs["type"],
+			Language: transcript.Attrs["language"],
+			Rel:      transcript.Attrs["rel"],
+		})
+	}
+	if chapters := elements["chapters"]; len(chapters) > 0 {
+		itemExt.Chapters = &Chapters{
+			URL:  chapters[0].Attrs["url"],
+			Type: chapters[0].Attrs["type"],
+		}
+	}
+	for _, person := range elements["person"] {
+		itemExt.Persons = append(itemExt.Persons, newPerson(person))
+	}
+	for _, soundbite := range elements["soundbite"] {
+		itemExt.Soundbites = append(itemExt.Soundbites, &Soundbite{
+			StartTime: parseFloat(soundbite.Attrs["startTime"]),
+			Duration:  parseFloat(soundbite.Attrs["duration"]),
+			Title:     soundbite.Value,
+		})
+	}
+	if season := elements["season"]; len(season) > 0 {
+		itemExt.Season = &Season{
+			Number: int(parseInt(season[0].Value)),
+			Name:   season[0].Attrs["name"],
+		}
+	}
+	if episode := elements["episode"]; len(episode) > 0 {
+		itemExt.Episode = &Episode{
+			Number:  parseFloat(episode[0].Value),
+			Display: episode[0].Attrs["display"],
+		}
+	}
+	for _, alternateEnclosure := range elements["alternateEnclosure"] {
+		itemExt.AlternateEnclosures = append(itemExt.AlternateEnclosures, newAlternateEnclosure(alternateEnclosure))
+	}
+	return itemExt
+}
+
+// getPodcastNamespacePrefix returns the prefix the root element of a feed document declares for the Podcasting 2.0
+// namespace. The conventional prefix "podcast" is returned if the namespace is not declared and the prefix is not
+// bound to another namespace either, an empty string is returned if the feed does not use the namespace
+func getPodcastNamespacePrefix(content string) string {
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	decoder.Strict = false
+	// The namespace declarations are ASCII, so the content does not have to be decoded from its charset
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	for {
+		token, err := decoder.RawToken()
+		if err != nil {
+			return podcastNamespacePrefix
+		}
+		startElement, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		prefix := podcastNamespacePrefix
+		for _, attr := range startElement.Attr {
+			if attr.Name.Space != "xmlns" {
+				continue
+			}
+			if podcastNamespaceURIs[strings.TrimSpace(attr.Value)] {
+				return attr.Name.Local
+			}
+			if attr.Name.Local == podcastNamespacePrefix {
+				prefix = ""
+			}
+		}
+		return prefix
+	}
+}
+
+// normalizePodcastNamespace moves the Podcasting 2.0 extensions of a parsed feed document and its items to
+// the podcastNamespacePrefix key. gofeed keys the extensions of the namespaces it does not know by the prefix
+// declared in the feed, so a feed that declares another prefix for the namespace would lose the elements
+func normalizePodcastNamespace(feed *gofeed.Feed, content string) {
+	prefix := getPodcastNamespacePrefix(content)
+	if prefix == podcastNamespacePrefix {
+		return
+	}
+	normalize := func(extensions ext.Extensions) {
+		elements, ok := extensions[prefix]
+		delete(extensions, podcastNamespacePrefix)
+		if ok && prefix != "" {
+			delete(extensions, prefix)
+			extensions[podcastNamespacePrefix] = elements
+		}
+	}
+	normalize(feed.Extensions)
+	for _, item := range feed.Items {
+		normalize(item.Extensions)
+	}
+}
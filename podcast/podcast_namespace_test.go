@@ -0,0 +1,132 @@
+package podcast
+
+import (
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const testPodcastNamespaceRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:podcast="https://podcastindex.org/namespace/1.0">
+<channel>
+<title>Example</title>
+<podcast:guid>917393e3-1b1e-5cef-ace4-edaa54e1f810</podcast:guid>
+<podcast:person role="host" img="http://example.com/host.jpg">Jane Doe</podcast:person>
+<podcast:funding url="http://example.com/donate">Support the show</podcast:funding>
+<podcast:location geo="geo:30.2672,97.7431" osm="R113314">Austin, TX</podcast:location>
+<podcast:license url="https://creativecommons.org/licenses/by/4.0/">cc-by-4.0</podcast:license>
+<item>
+<title>Episode 1</title>
+<podcast:transcript url="http://example.com/1.srt" type="application/srt" language="en"/>
+<podcast:transcript url="http://example.com/1.vtt" type="text/vtt" rel="captions"/>
+<podcast:chapters url="http://example.com/1.json" type="application/json+chapters"/>
+<podcast:person role="guest" href="http://example.com/guest">John Smith</podcast:person>
+<podcast:soundbite startTime="73.0" duration="60.5">Highlight</podcast:soundbite>
+<podcast:season name="Pilot">1</podcast:season>
+<podcast:episode display="Ch. 1">1.5</podcast:episode>
+<podcast:alternateEnclosure type="audio/opus" length="1024" bitrate="64000.5" default="true" title="Opus">
+<podcast:source uri="http://example.com/1.opus"/>
+<podcast:source uri="ipfs://example" contentType="audio/opus"/>
+<podcast:integrity type="sri" value="sha384-example"/>
+</podcast:alternateEnclosure>
+<enclosure url="http://example.com/1.mp3" length="1024" type="audio/mpeg"/>
+</item>
+<item>
+<title>Episode 2</title>
+</item>
+</channel>
+</rss>`
+
+func TestNewPodcastFromFeed_PodcastNamespace(t *testing.T) {
+	feed, err := gofeed.NewParser().ParseString(testPodcastNamespaceRSS)
+	assert.Nil(t, err)
+	podcast := newPodcastFromFeed("http://example.com/rss.xml", feed)
+
+	feedExt := podcast.PodcastExt
+	assert.NotNil(t, feedExt)
+	assert.Equal(t, "917393e3-1b1e-5cef-ace4-edaa54e1f810", feedExt.GUID)
+	assert.Equal(t, []*Person{{Name: "Jane Doe", Role: "host", Image: "http://example.com/host.jpg"}}, feedExt.Persons)
+	assert.Equal(t, []*Funding{{URL: "http://example.com/donate", Text: "Support the show"}}, feedExt.Funding)
+	assert.Equal(t, &Location{Name: "Austin, TX", Geo: "geo:30.2672,97.7431", OSM: "R113314"}, feedExt.Location)
+	assert.Equal(t, &License{Name: "cc-by-4.0", URL: "https://creativecommons.org/licenses/by/4.0/"}, feedExt.License)
+
+	itemExt := podcast.Items[0].PodcastExt
+	assert.NotNil(t, itemExt)
+	assert.Equal(t, []*Transcript{
+		{URL: "http://example.com/1.srt", Type: "application/srt", Language: "en"},
+		{URL: "http://example.com/1.vtt", Type: "text/vtt", Rel: "captions"},
+	}, itemExt.Transcripts)
+	assert.Equal(t, &Chapters{URL: "http://example.com/1.json", Type: "application/json+chapters"}, itemExt.Chapters)
+	assert.Equal(t, []*Person{{Name: "John Smith", Role: "guest", Href: "http://example.com/guest"}}, itemExt.Persons)
+	assert.Equal(t, []*Soundbite{{StartTime: 73, Duration: 60.5, Title: "Highlight"}}, itemExt.Soundbites)
+	assert.Equal(t, &Season{Number: 1, Name: "Pilot"}, itemExt.Season)
+	assert.Equal(t, &Episode{Number: 1.5, Display: "Ch. 1"}, itemExt.Episode)
+	assert.Equal(t, []*AlternateEnclosure{{
+		Type:    "audio/opus",
+		Length:  1024,
+		Bitrate: 64000.5,
+		Title:   "Opus",
+		Default: true,
+		Sources: []*AlternateEnclosureSource{
+			{URI: "http://example.com/1.opus"},
+			{URI: "ipfs://example", ContentType: "audio/opus"},
+		},
+		Integrity: &Integrity{Type: "sri", Value: "sha384-example"},
+	}}, itemExt.AlternateEnclosures)
+	assert.Nil(t, podcast.Items[1].PodcastExt)
+
+	itemJSON, err := podcast.Items[0].GetJSON()
+	assert.Nil(t, err)
+	assert.Contains(t, itemJSON, `"podcastExt":{"transcripts":[{"url":"http://example.com/1.srt"`)
+	podcastJSON, err := podcast.GetJSON()
+	assert.Nil(t, err)
+	assert.Contains(t, podcastJSON, `"podcastExt":{"guid":"917393e3-1b1e-5cef-ace4-edaa54e1f810"`)
+}
+
+func TestNewPodcastFromFeed_WithoutPodcastNamespace(t *testing.T) {
+	feed, err := gofeed.NewParser().ParseString(testRSS)
+	assert.Nil(t, err)
+	podcast := newPodcastFromFeed("http://example.com/rss.xml", feed)
+	assert.Nil(t, podcast.PodcastExt)
+	assert.Nil(t, podcast.Items[0].PodcastExt)
+}
+
+func TestGetPodcastNamespacePrefix(t *testing.T) {
+	for content, expected := range map[string]string{
+		testPodcastNamespaceRSS: "podcast",
+		testRSS:                 "podcast",
+		`<rss xmlns:pi="https://podcastindex.org/namespace/1.0"><channel/></rss>`:                                      "pi",
+		`<rss xmlns:pi="https://github.com/Podcastindex-org/podcast-namespace/blob/main/docs/1.0.md"><channel/></rss>`: "pi",
+		`<rss xmlns:podcast="http://example.com/other"><channel/></rss>`:                                               "",
+		`<?xml version="1.0" encoding="ISO-8859-1"?><rss xmlns:pi="https://podcastindex.org/namespace/1.0"/>`:          "pi",
+	} {
+		assert.Equal(t, expected, getPodcastNamespacePrefix(content), content)
+	}
+}
+
+func TestNormalizePodcastNamespace(t *testing.T) {
+	content := strings.NewReplacer(
+		`xmlns:podcast="https://podcastindex.org/namespace/1.0"`,
+		`xmlns:pi="https://podcastindex.org/namespace/1.0" xmlns:podcast="http://example.com/other"`,
+		"<podcast:", "<pi:",
+		"</podcast:", "</pi:",
+	).Replace(testPodcastNamespaceRSS)
+	content = strings.Replace(content, "<title>Episode 1</title>", "<title>Episode 1</title><podcast:chapters url=\"http://example.com/other.json\"/>", 1)
+	feed, err := gofeed.NewParser().ParseString(content)
+	assert.Nil(t, err)
+	normalizePodcastNamespace(feed, content)
+	podcast := newPodcastFromFeed("http://example.com/rss.xml", feed)
+	assert.NotNil(t, podcast.PodcastExt)
+	assert.Equal(t, "917393e3-1b1e-5cef-ace4-edaa54e1f810", podcast.PodcastExt.GUID)
+	itemExt := podcast.Items[0].PodcastExt
+	assert.NotNil(t, itemExt)
+	assert.Len(t, itemExt.Transcripts, 2)
+	assert.Equal(t, &Chapters{URL: "http://example.com/1.json", Type: "application/json+chapters"}, itemExt.Chapters)
+
+	content = `<rss xmlns:podcast="http://example.com/other"><channel><title>Example</title><podcast:guid>other</podcast:guid></channel></rss>`
+	feed, err = gofeed.NewParser().ParseString(content)
+	assert.Nil(t, err)
+	normalizePodcastNamespace(feed, content)
+	assert.Nil(t, newPodcastFromFeed("http://example.com/rss.xml", feed).PodcastExt)
+}
@@ -0,0 +1,117 @@
+package podcast
+
+import (
+	podownloader "PoDownloader"
+	"PoDownloader/util"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Transcript formats, they are used as the file extension names of the downloaded transcripts
+const (
+	TranscriptFormatSRT  = "srt"
+	TranscriptFormatVTT  = "vtt"
+	TranscriptFormatJSON = "json"
+	TranscriptFormatHTML = "html"
+	TranscriptFormatText = "txt"
+)
+
+// transcriptFormatsByMimeType maps the transcript types declared in podcast:transcript to the transcript formats
+var transcriptFormatsByMimeType = map[string]string{
+	"application/srt":      TranscriptFormatSRT,
+	"application/x-srt":    TranscriptFormatSRT,
+	"application/x-subrip": TranscriptFormatSRT,
+	"text/srt":             TranscriptFormatSRT,
+	"text/vtt":             TranscriptFormatVTT,
+	"application/json":     TranscriptFormatJSON,
+	"text/html":            TranscriptFormatHTML,
+	"text/plain":           TranscriptFormatText,
+}
+
+// AllTranscriptFormats are all supported transcript formats
+var AllTranscriptFormats = []string{TranscriptFormatSRT, TranscriptFormatVTT, TranscriptFormatJSON, TranscriptFormatHTML, TranscriptFormatText}
+
+// ParseTranscriptFormats returns the transcript formats in specified comma separated text,
+// an empty text means no transcripts are downloaded
+func ParseTranscriptFormats(text string) ([]string, error) {
+	var formats []string
+	for _, format := range strings.Split(text, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if format == "" {
+			continue
+		}
+		if !util.IsStringSliceContainText(AllTranscriptFormats, format) {
+			return nil, fmt.Errorf("unknown transcript format: %s, available formats: %s", format, strings.Join(AllTranscriptFormats, ", "))
+		}
+		formats = append(formats, format)
+	}
+	return formats, nil
+}
+
+// GetFormat returns the format of the transcript determined by its declared type first,
+// and then by the file extension name of its URL, returns an empty string if the format is unknown
+func (t *Transcript) GetFormat() string {
+	mediaType, _, err := mime.ParseMediaType(t.Type)
+	if err == nil {
+		if format, ok := transcriptFormatsByMimeType[strings.ToLower(mediaType)]; ok {
+			return format
+		}
+	}
+	transcriptURL, err := url.Parse(t.URL)
+	if err != nil {
+		return ""
+	}
+	extensionName := strings.ToLower(strings.TrimPrefix(path.Ext(transcriptURL.Path), "."))
+	if extensionName == "htm" {
+		return TranscriptFormatHTML
+	}
+	if util.IsStringSliceContainText(AllTranscriptFormats, extensionName) {
+		return extensionName
+	}
+	return ""
+}
+
+// GetTranscriptDownloadTasks returns the download tasks of the podcast:transcript links of the item in specified formats,
+// the transcripts are saved in destDir and named after the episode with the format as the extension name.
+// An index is appended to the file name when the item has several transcripts in the same format
+func (i *Item) GetTranscriptDownloadTasks(podcastTitle string, destDir string, formats []string) []*podownloader.URLDownloadTask {
+	if i.PodcastExt == nil {
+		return nil
+	}
+	var (
+		transcripts       []*Transcript
+		transcriptFormats []string
+		formatCounts      = make(map[string]int)
+	)
+	for _, transcript := range i.PodcastExt.Transcripts {
+		format := transcript.GetFormat()
+		if transcript.URL == "" || format == "" || !util.IsStringSliceContainText(formats, format) {
+			continue
+		}
+		transcripts = append(transcripts, transcript)
+		transcriptFormats = append(transcriptFormats, format)
+		formatCounts[format]++
+	}
+	var (
+		transcriptDownloadTasks []*podownloader.URLDownloadTask
+		formatIndexes           = make(map[string]int)
+	)
+	for index, transcript := range transcripts {
+		format := transcriptFormats[index]
+		fileName := fmt.Sprintf("%s.%s", i.SafeTitle, format)
+		if formatCounts[format] > 1 {
+			formatIndexes[format]++
+			fileName = fmt.Sprintf("%s_%d.%s", i.SafeTitle, formatIndexes[format], format)
+		}
+		transcriptDownloadTasks = append(transcriptDownloadTasks, &podownloader.URLDownloadTask{
+			JobName: fmt.Sprintf("%s - %s", podcastTitle, i.Title),
+			JobType: podownloader.JobTypeTranscript,
+			URL:     transcript.URL,
+			Dest:    path.Join(destDir, fileName),
+		})
+	}
+	return transcriptDownloadTasks
+}
@@ -0,0 +1,76 @@
+package podcast
+
+import (
+	podownloader "PoDownloader"
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestTranscript_GetFormat(t *testing.T) {
+	assert.Equal(t, TranscriptFormatSRT, (&Transcript{URL: "http://example.com/1", Type: "application/x-subrip"}).GetFormat())
+	assert.Equal(t, TranscriptFormatVTT, (&Transcript{URL: "http://example.com/1", Type: "text/vtt; charset=utf-8"}).GetFormat())
+	assert.Equal(t, TranscriptFormatJSON, (&Transcript{URL: "http://example.com/1", Type: "application/json"}).GetFormat())
+	assert.Equal(t, TranscriptFormatHTML, (&Transcript{URL: "http://example.com/1", Type: "text/html"}).GetFormat())
+	// The extension name of the URL is used when the type is unknown
+	assert.Equal(t, TranscriptFormatSRT, (&Transcript{URL: "http://example.com/1.SRT?token=1", Type: "application/octet-stream"}).GetFormat())
+	assert.Equal(t, TranscriptFormatHTML, (&Transcript{URL: "http://example.com/1.htm"}).GetFormat())
+	assert.Equal(t, "", (&Transcript{URL: "http://example.com/1.pdf", Type: "application/pdf"}).GetFormat())
+}
+
+func TestParseTranscriptFormats(t *testing.T) {
+	formats, err := ParseTranscriptFormats("srt, VTT,,json")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{TranscriptFormatSRT, TranscriptFormatVTT, TranscriptFormatJSON}, formats)
+	formats, err = ParseTranscriptFormats("")
+	assert.Nil(t, err)
+	assert.Empty(t, formats)
+	_, err = ParseTranscriptFormats("srt,pdf")
+	assert.NotNil(t, err)
+}
+
+func TestItem_GetTranscriptDownloadTasks(t *testing.T) {
+	item := &Item{
+		Title:     "Episode 1",
+		SafeTitle: "Episode 1",
+		PodcastExt: &PodcastItemExtension{
+			Transcripts: []*Transcript{
+				{URL: "http://example.com/en.srt", Type: "application/srt", Language: "en"},
+				{URL: "http://example.com/1.vtt", Type: "text/vtt"},
+				{URL: "http://example.com/fr.srt", Type: "application/srt", Language: "fr"},
+				{URL: "http://example.com/1.pdf", Type: "application/pdf"},
+			},
+		},
+	}
+	tasks := item.GetTranscriptDownloadTasks("Example", "/tmp/Example/Episode 1", AllTranscriptFormats)
+	assert.Equal(t, 3, len(tasks))
+	assert.Equal(t, "/tmp/Example/Episode 1/Episode 1_1.srt", tasks[0].Dest)
+	assert.Equal(t, "/tmp/Example/Episode 1/Episode 1.vtt", tasks[1].Dest)
+	assert.Equal(t, "/tmp/Example/Episode 1/Episode 1_2.srt", tasks[2].Dest)
+	assert.Equal(t, podownloader.JobTypeTranscript, tasks[0].JobType)
+	assert.Equal(t, "http://example.com/en.srt", tasks[0].URL)
+	assert.Equal(t, "Example - Episode 1", tasks[0].JobName)
+
+	tasks = item.GetTranscriptDownloadTasks("Example", "/tmp/Example/Episode 1", []string{TranscriptFormatVTT})
+	assert.Equal(t, 1, len(tasks))
+	assert.Equal(t, "/tmp/Example/Episode 1/Episode 1.vtt", tasks[0].Dest)
+	assert.Empty(t, item.GetTranscriptDownloadTasks("Example", "/tmp", nil))
+	assert.Empty(t, (&Item{Title: "Episode 2"}).GetTranscriptDownloadTasks("Example", "/tmp", AllTranscriptFormats))
+}
+
+func TestPodcast_GetPodcastDownloadTask_Transcripts(t *testing.T) {
+	feed, err := gofeed.NewParser().ParseString(testPodcastNamespaceRSS)
+	assert.Nil(t, err)
+	podcast := newPodcastFromFeed("http://example.com/rss.xml", feed)
+
+	podcastDownloadTask := podcast.GetPodcastDownloadTask("/tmp", &http.Client{}, nil, nil)
+	transcriptDownloadTasks := podcastDownloadTask.EpisodeDownloadTasks[0].TranscriptDownloadTasks
+	assert.Equal(t, 2, len(transcriptDownloadTasks))
+	assert.Equal(t, "/tmp/Example/Episode 1/Episode 1.srt", transcriptDownloadTasks[0].Dest)
+	assert.Equal(t, "/tmp/Example/Episode 1/Episode 1.vtt", transcriptDownloadTasks[1].Dest)
+	assert.Empty(t, podcastDownloadTask.EpisodeDownloadTasks[1].TranscriptDownloadTasks)
+
+	podcastDownloadTask = podcast.GetPodcastDownloadTask("/tmp", &http.Client{}, nil, &TaskOptions{TranscriptFormats: nil})
+	assert.Empty(t, podcastDownloadTask.EpisodeDownloadTasks[0].TranscriptDownloadTasks)
+}
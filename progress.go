@@ -0,0 +1,48 @@
+package podownloader
+
+import (
+	"PoDownloader/util"
+	"fmt"
+	"github.com/vbauerster/mpb/v8"
+	"os"
+)
+
+// ProgressMode determines how the progress of parsing feeds, checking downloaded files and downloading is displayed
+type ProgressMode string
+
+const (
+	// ProgressModeBar displays progress bars on stdout
+	ProgressModeBar ProgressMode = "bar"
+	// ProgressModePlain hides the progress bars, the progress is reported by the logged events instead
+	ProgressModePlain ProgressMode = "plain"
+)
+
+// DetectProgressMode returns ProgressModeBar if stdout is a terminal, otherwise ProgressModePlain,
+// so that the escape codes of the progress bars are not written to log files, pipes, cron mails and CI logs
+func DetectProgressMode() ProgressMode {
+	if util.IsTerminal(os.Stdout) {
+		return ProgressModeBar
+	}
+	return ProgressModePlain
+}
+
+// ParseProgressMode returns the ProgressMode corresponding to specified text, "auto" is resolved by DetectProgressMode
+func ParseProgressMode(text string) (ProgressMode, error) {
+	if text == "auto" {
+		return DetectProgressMode(), nil
+	}
+	switch progressMode := ProgressMode(text); progressMode {
+	case ProgressModeBar, ProgressModePlain:
+		return progressMode, nil
+	}
+	return "", fmt.Errorf("unknown progress mode: %s, available modes: auto, bar, plain", text)
+}
+
+// NewProgress returns an mpb.Progress container that renders the progress bars only in ProgressModeBar,
+// the bars of other modes are discarded. The empty ProgressMode is treated as ProgressModeBar
+func NewProgress(progressMode ProgressMode, options ...mpb.ContainerOption) *mpb.Progress {
+	if progressMode == ProgressModePlain {
+		options = append(options, mpb.WithOutput(nil))
+	}
+	return mpb.New(options...)
+}
@@ -0,0 +1,20 @@
+package podownloader
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseProgressMode(t *testing.T) {
+	progressMode, err := ParseProgressMode("bar")
+	assert.Nil(t, err)
+	assert.Equal(t, ProgressModeBar, progressMode)
+	progressMode, err = ParseProgressMode("plain")
+	assert.Nil(t, err)
+	assert.Equal(t, ProgressModePlain, progressMode)
+	progressMode, err = ParseProgressMode("auto")
+	assert.Nil(t, err)
+	assert.Equal(t, DetectProgressMode(), progressMode)
+	_, err = ParseProgressMode("foobar")
+	assert.NotNil(t, err)
+}
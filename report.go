@@ -0,0 +1,213 @@
+package podownloader
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReportFormat is the file format of a RunReport
+type ReportFormat string
+
+const (
+	// ReportFormatJSON writes the report as a JSON document
+	ReportFormatJSON ReportFormat = "json"
+	// ReportFormatCSV writes the report as a CSV table with a row for every feed and task
+	ReportFormatCSV ReportFormat = "csv"
+)
+
+// GetReportFormatByPath returns the report format according to the extension name of specified path,
+// ".csv" is ReportFormatCSV and others are ReportFormatJSON
+func GetReportFormatByPath(path string) ReportFormat {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return ReportFormatCSV
+	}
+	return ReportFormatJSON
+}
+
+// FeedResult records the result of parsing a podcast feed
+type FeedResult struct {
+	URL   string
+	Title string
+	// Items is the number of items in the feed
+	Items int
+	// NotModified is true if the feed is not modified since it was cached
+	NotModified bool
+	Duration    time.Duration
+	// Err is a *FeedError if the feed could not be fetched or parsed
+	Err error
+}
+
+// ErrorClass returns the class of FeedResult.Err, empty if the feed was parsed successfully
+func (r *FeedResult) ErrorClass() ErrorClass {
+	return ClassifyError(r.Err)
+}
+
+// FeedError returns the FeedError of FeedResult.Err, nil if the feed was parsed successfully
+// or the feed failed for a reason other than fetching and parsing
+func (r *FeedResult) FeedError() *FeedError {
+	return GetFeedError(r.Err)
+}
+
+// RunReport is the machine-readable report of a download run
+type RunReport struct {
+	StartTime time.Time
+	EndTime   time.Time
+	Feeds     []*FeedResult
+	Tasks     []*TaskResult
+}
+
+// reportRecord is a row of the report, it is shared by feeds and tasks
+type reportRecord struct {
+	Record     string `json:"-"`
+	JobType    string `json:"jobType,omitempty"`
+	URL        string `json:"url"`
+	Title      string `json:"title,omitempty"`
+	Dest       string `json:"dest,omitempty"`
+	Status     string `json:"status"`
+	Items      int    `json:"items,omitempty"`
+	Bytes      int64  `json:"bytes,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+	Attempts   int    `json:"attempts,omitempty"`
+	HTTPStatus int    `json:"httpStatus,omitempty"`
+	ErrorClass string `json:"errorClass,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// reportRecordHeader is the header of the CSV report
+var reportRecordHeader = []string{"record", "jobType", "url", "title", "dest", "status", "items", "bytes", "durationMs", "attempts", "httpStatus", "errorClass", "error"}
+
+// toCSVRow returns the CSV row of reportRecord in the order of reportRecordHeader
+func (r *reportRecord) toCSVRow() []string {
+	return []string{
+		r.Record,
+		r.JobType,
+		r.URL,
+		r.Title,
+		r.Dest,
+		r.Status,
+		strconv.Itoa(r.Items),
+		strconv.FormatInt(r.Bytes, 10),
+		strconv.FormatInt(r.DurationMs, 10),
+		strconv.Itoa(r.Attempts),
+		strconv.Itoa(r.HTTPStatus),
+		r.ErrorClass,
+		r.Error,
+	}
+}
+
+// toReportRecord converts FeedResult to a reportRecord
+func (r *FeedResult) toReportRecord() *reportRecord {
+	record := &reportRecord{
+		Record:     "feed",
+		URL:        r.URL,
+		Title:      r.Title,
+		Status:     "done",
+		Items:      r.Items,
+		DurationMs: r.Duration.Milliseconds(),
+		ErrorClass: string(r.ErrorClass()),
+	}
+	if r.NotModified {
+		record.Status = "not-modified"
+	}
+	if r.Err != nil {
+		record.Status = "failed"
+		record.Error = r.Err.Error()
+	}
+	return record
+}
+
+// toReportRecord converts TaskResult to a reportRecord
+func (r *TaskResult) toReportRecord() *reportRecord {
+	record := &reportRecord{
+		Record:     "task",
+		JobType:    r.JobType,
+		Title:      r.Title,
+		URL:        r.URL,
+		Dest:       r.Dest,
+		Status:     string(r.Status),
+		Bytes:      r.Bytes,
+		DurationMs: r.Duration.Milliseconds(),
+		Attempts:   r.Attempts,
+		HTTPStatus: r.HTTPStatus,
+		ErrorClass: string(r.ErrorClass()),
+	}
+	if r.Err != nil {
+		record.Error = r.Err.Error()
+	}
+	return record
+}
+
+// WriteJSON writes the report to w as a JSON document
+func (r *RunReport) WriteJSON(w io.Writer) error {
+	report := struct {
+		StartTime time.Time       `json:"startTime"`
+		EndTime   time.Time       `json:"endTime"`
+		Feeds     []*reportRecord `json:"feeds"`
+		Tasks     []*reportRecord `json:"tasks"`
+	}{
+		StartTime: r.StartTime,
+		EndTime:   r.EndTime,
+		Feeds:     make([]*reportRecord, 0, len(r.Feeds)),
+		Tasks:     make([]*reportRecord, 0, len(r.Tasks)),
+	}
+	for _, feedResult := range r.Feeds {
+		report.Feeds = append(report.Feeds, feedResult.toReportRecord())
+	}
+	for _, taskResult := range r.Tasks {
+		report.Tasks = append(report.Tasks, taskResult.toReportRecord())
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// WriteCSV writes the report to w as a CSV table, feeds are followed by tasks
+func (r *RunReport) WriteCSV(w io.Writer) error {
+	csvWriter := csv.NewWriter(w)
+	err := csvWriter.Write(reportRecordHeader)
+	if err != nil {
+		return err
+	}
+	for _, feedResult := range r.Feeds {
+		err = csvWriter.Write(feedResult.toReportRecord().toCSVRow())
+		if err != nil {
+			return err
+		}
+	}
+	for _, taskResult := range r.Tasks {
+		err = csvWriter.Write(taskResult.toReportRecord().toCSVRow())
+		if err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// Save writes the report to specified path in specified format
+func (r *RunReport) Save(path string, format ReportFormat) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case ReportFormatCSV:
+		err = r.WriteCSV(file)
+	case ReportFormatJSON:
+		err = r.WriteJSON(file)
+	default:
+		err = fmt.Errorf("unknown report format: %s", format)
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
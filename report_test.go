@@ -0,0 +1,61 @@
+package podownloader
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRunReport() *RunReport {
+	return &RunReport{
+		StartTime: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndTime:   time.Date(2021, 6, 1, 0, 1, 0, 0, time.UTC),
+		Feeds: []*FeedResult{
+			{URL: "https://example.com/rss.xml", Title: "Example", Items: 2, Duration: time.Second},
+			{URL: "https://example.org/rss.xml", Err: errors.New("foobar")},
+		},
+		Tasks: []*TaskResult{
+			{JobType: JobTypeEnclosure, URL: "https://example.com/1.mp3", Dest: "1.mp3", Status: TaskResultDone, Bytes: 1024, Duration: 2 * time.Second, Attempts: 1, HTTPStatus: 200},
+			{JobType: JobTypeCover, URL: "https://example.com/1.jpg", Dest: "1.jpg", Status: TaskResultFailed, Attempts: 3, HTTPStatus: 404, Err: &HTTPStatusError{URL: "https://example.com/1.jpg", StatusCode: 404}},
+		},
+	}
+}
+
+func TestRunReport_WriteJSON(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.Nil(t, newTestRunReport().WriteJSON(&buffer))
+	var report struct {
+		Feeds []map[string]interface{} `json:"feeds"`
+		Tasks []map[string]interface{} `json:"tasks"`
+	}
+	assert.Nil(t, json.Unmarshal(buffer.Bytes(), &report))
+	assert.Equal(t, 2, len(report.Feeds))
+	assert.Equal(t, "done", report.Feeds[0]["status"])
+	assert.Equal(t, "failed", report.Feeds[1]["status"])
+	assert.Equal(t, "foobar", report.Feeds[1]["error"])
+	assert.Equal(t, 2, len(report.Tasks))
+	assert.Equal(t, float64(1024), report.Tasks[0]["bytes"])
+	assert.Equal(t, float64(2000), report.Tasks[0]["durationMs"])
+	assert.Equal(t, "http-status", report.Tasks[1]["errorClass"])
+	assert.Equal(t, float64(404), report.Tasks[1]["httpStatus"])
+}
+
+func TestRunReport_WriteCSV(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.Nil(t, newTestRunReport().WriteCSV(&buffer))
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	assert.Equal(t, 5, len(lines))
+	assert.Equal(t, "record,jobType,url,title,dest,status,items,bytes,durationMs,attempts,httpStatus,errorClass,error", lines[0])
+	assert.Equal(t, "feed,,https://example.com/rss.xml,Example,,done,2,0,1000,0,0,,", lines[1])
+	assert.Equal(t, "task,Enclosure,https://example.com/1.mp3,,1.mp3,done,0,1024,2000,1,200,,", lines[3])
+}
+
+func TestGetReportFormatByPath(t *testing.T) {
+	assert.Equal(t, ReportFormatCSV, GetReportFormatByPath("report.CSV"))
+	assert.Equal(t, ReportFormatJSON, GetReportFormatByPath("report.json"))
+	assert.Equal(t, ReportFormatJSON, GetReportFormatByPath("report"))
+}
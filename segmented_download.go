@@ -0,0 +1,387 @@
+package podownloader
+
+import (
+	"PoDownloader/util"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/vbauerster/mpb/v8"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// minSegmentSize is the minimum size of a download segment
+	minSegmentSize = 1024 * 1024
+	// segmentMetaSaveInterval is the interval to save the progress of the segments to the partial download metadata file
+	segmentMetaSaveInterval = 5 * time.Second
+	// segmentBufferSize is the size of the buffer used to copy a segment to the partial file
+	segmentBufferSize = 32 * 1024
+)
+
+// errSegmentsNotResumable is returned when the server does not return the requested range of a segment,
+// which means the server does not support range requests anymore or the remote file has changed
+var errSegmentsNotResumable = errors.New("segments can not be resumed")
+
+// downloadSegment is a byte range of a segmented download,
+// Downloaded is accessed atomically while the segment is being downloaded
+type downloadSegment struct {
+	Start int64 `json:"start"`
+	// End is the last byte position of the segment, inclusive
+	End        int64 `json:"end"`
+	Downloaded int64 `json:"downloaded"`
+}
+
+// remaining returns the number of bytes of the segment that have not been downloaded
+func (s *downloadSegment) remaining() int64 {
+	return s.End - s.Start + 1 - atomic.LoadInt64(&s.Downloaded)
+}
+
+// splitSegments splits the content of specified length into count segments of almost the same size
+func splitSegments(length int64, count int) []*downloadSegment {
+	segments := make([]*downloadSegment, 0, count)
+	segmentSize := length / int64(count)
+	for i := 0; i < count; i++ {
+		start := int64(i) * segmentSize
+		end := start + segmentSize - 1
+		if i == count-1 {
+			end = length - 1
+		}
+		segments = append(segments, &downloadSegment{Start: start, End: end})
+	}
+	return segments
+}
+
+// snapshot returns a copy of the partial download metadata with the current progress of the segments,
+// so that it can be saved while the segments are being downloaded
+func (m *partialDownloadMeta) snapshot() *partialDownloadMeta {
+	meta := *m
+	meta.Segments = make([]*downloadSegment, 0, len(m.Segments))
+	for _, segment := range m.Segments {
+		meta.Segments = append(meta.Segments, &downloadSegment{
+			Start:      segment.Start,
+			End:        segment.End,
+			Downloaded: atomic.LoadInt64(&segment.Downloaded),
+		})
+	}
+	return &meta
+}
+
+// downloaded returns the number of downloaded bytes of all segments
+func (m *partialDownloadMeta) downloaded() int64 {
+	var downloaded int64
+	for _, segment := range m.Segments {
+		downloaded += atomic.LoadInt64(&segment.Downloaded)
+	}
+	return downloaded
+}
+
+// segmentedPartialMeta returns the partial download metadata of a segmented download of URLDownloadTask,
+// returns nil if there is no segmented partial file
+func (c *URLDownloadTask) segmentedPartialMeta() *partialDownloadMeta {
+	meta, err := loadPartialDownloadMeta(c.partialMetaFilePath())
+	if err != nil || meta.URL != c.URL || len(meta.Segments) == 0 || meta.Length <= 0 || meta.ifRangeValidator() == "" {
+		return nil
+	}
+	size, err := util.GetFileSize(c.PartialFilePath())
+	if err != nil || size != meta.Length {
+		return nil
+	}
+	return meta
+}
+
+// planSegments returns the segments to download the content of specified response in parallel,
+// the slots of the additional connections are acquired from TaskEnv.ThreadBudget and TaskEnv.HostLimiter.
+// Returns nil if the content should be downloaded in a single connection
+func (c *URLDownloadTask) planSegments(env *TaskEnv, resp *http.Response) []*downloadSegment {
+	options := env.Options
+	if options.SegmentCount < 2 || env.ThreadBudget == nil || c.JobType != JobTypeEnclosure {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Accept-Ranges") != "bytes" ||
+		resp.ContentLength < options.SegmentThreshold || resp.ContentLength < 2*minSegmentSize {
+		return nil
+	}
+	count := options.SegmentCount
+	if maxCount := resp.ContentLength / minSegmentSize; int64(count) > maxCount {
+		count = int(maxCount)
+	}
+	extra := c.acquireExtraConnections(env, count-1)
+	if extra == 0 {
+		return nil
+	}
+	return splitSegments(resp.ContentLength, extra+1)
+}
+
+// acquireExtraConnections acquires up to n slots for the additional connections of a segmented download without blocking,
+// each connection takes a slot of TaskEnv.ThreadBudget and a slot of the host of the task in TaskEnv.HostLimiter,
+// returns the number of acquired connections
+func (c *URLDownloadTask) acquireExtraConnections(env *TaskEnv, n int) int {
+	extra := env.ThreadBudget.TryAcquire(n)
+	if env.HostLimiter != nil {
+		hostExtra := env.HostLimiter.TryAcquireExtra(util.GetURLHost(c.URL), extra)
+		env.ThreadBudget.Release(extra - hostExtra)
+		extra = hostExtra
+	}
+	return extra
+}
+
+// releaseExtraConnections releases the slots of n additional connections acquired by acquireExtraConnections
+func (c *URLDownloadTask) releaseExtraConnections(env *TaskEnv, n int) {
+	env.ThreadBudget.Release(n)
+	if env.HostLimiter != nil {
+		env.HostLimiter.ReleaseExtra(util.GetURLHost(c.URL), n)
+	}
+}
+
+// startSegments downloads the content of specified response in the planned segments,
+// the response body is used for the first segment, and the slots acquired by planSegments are released when done
+func (c *URLDownloadTask) startSegments(ctx context.Context, env *TaskEnv, resp *http.Response, segments []*downloadSegment) error {
+	defer c.releaseExtraConnections(env, len(segments)-1)
+	meta := newPartialDownloadMeta(c.URL, resp)
+	meta.Segments = segments
+	// The partial file is allocated to the full length, so the whole content must fit in the free disk space
+	if env.Options.MinFreeSpace > 0 {
+		err := CheckDiskSpace(c.PartialFilePath(), meta.Length+env.Options.MinFreeSpace)
+		if err != nil {
+			return err
+		}
+	}
+	out, err := os.OpenFile(c.PartialFilePath(), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	err = out.Truncate(meta.Length)
+	if err == nil {
+		err = meta.save(c.partialMetaFilePath())
+	}
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return c.downloadSegments(ctx, env, out, meta, len(segments), resp.Body)
+}
+
+// resumeSegments continues the unfinished segments of a segmented partial file,
+// additional connections are used if there are idle slots in TaskEnv.ThreadBudget and TaskEnv.HostLimiter
+func (c *URLDownloadTask) resumeSegments(ctx context.Context, env *TaskEnv, meta *partialDownloadMeta) error {
+	unfinished := 0
+	for _, segment := range meta.Segments {
+		if segment.remaining() > 0 {
+			unfinished++
+		}
+	}
+	connections := 1
+	if env.ThreadBudget != nil && unfinished > 1 {
+		count := env.Options.SegmentCount
+		if count > unfinished {
+			count = unfinished
+		}
+		if count > 1 {
+			extra := c.acquireExtraConnections(env, count-1)
+			defer c.releaseExtraConnections(env, extra)
+			connections += extra
+		}
+	}
+	out, err := os.OpenFile(c.PartialFilePath(), os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	return c.downloadSegments(ctx, env, out, meta, connections, nil)
+}
+
+// downloadSegments downloads the unfinished segments of meta to the partial file out with specified number of connections
+// and finalizes the partial file when all segments are downloaded, out will be closed.
+// If firstBody is not nil, it is the response body of the first segment.
+// The progress of the segments is saved periodically and when the download fails, so that the segments can be resumed
+func (c *URLDownloadTask) downloadSegments(ctx context.Context, env *TaskEnv, out *os.File, meta *partialDownloadMeta, connections int, firstBody io.Reader) error {
+	ctx, cancelFunc := context.WithCancel(ctx)
+	defer cancelFunc()
+	var (
+		segmentChan  = make(chan *downloadSegment, len(meta.Segments))
+		rateLimiters = env.Options.rateLimiters()
+		guard        = newDiskSpaceGuard(c.PartialFilePath(), env.Options.MinFreeSpace)
+		progress     = &segmentProgress{stats: env.Stats, guard: guard, lastUpdate: time.Now(), lock: &sync.Mutex{}}
+		doneWg       = new(sync.WaitGroup)
+		firstErr     error
+		errOnce      sync.Once
+	)
+	for index, segment := range meta.Segments {
+		if segment.remaining() > 0 && (firstBody == nil || index > 0) {
+			segmentChan <- segment
+		}
+	}
+	close(segmentChan)
+	if env.ProgressBar != nil {
+		downloaded := meta.downloaded()
+		progress.bar = c.addProgressBar(env.ProgressBar, meta.Length-downloaded, downloaded, env.Attempt)
+	}
+	setErr := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancelFunc()
+		})
+	}
+
+	doneWg.Add(connections)
+	for i := 0; i < connections; i++ {
+		go func(first bool) {
+			defer doneWg.Done()
+			if first && firstBody != nil {
+				body := util.NewRateLimitedReader(ctx, firstBody, rateLimiters...)
+				err := c.copySegment(out, meta.Segments[0], body, progress)
+				if err != nil {
+					setErr(err)
+					return
+				}
+			}
+			for segment := range segmentChan {
+				if ctx.Err() != nil {
+					return
+				}
+				err := c.downloadSegment(ctx, env.HTTPClient, out, meta, segment, rateLimiters, progress)
+				if err != nil {
+					setErr(err)
+					return
+				}
+			}
+		}(i == 0)
+	}
+
+	// Save the progress of the segments periodically until all connections are done
+	finished := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(segmentMetaSaveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-finished:
+				return
+			case <-ticker.C:
+				if out.Sync() == nil {
+					_ = meta.snapshot().save(c.partialMetaFilePath())
+				}
+			}
+		}
+	}()
+	doneWg.Wait()
+	close(finished)
+
+	err := firstErr
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		// Make sure the content is flushed to disk before the partial file is renamed
+		err = out.Sync()
+	} else if out.Sync() == nil {
+		_ = meta.snapshot().save(c.partialMetaFilePath())
+	}
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if progress.bar != nil {
+			progress.bar.Abort(false)
+		}
+		return err
+	}
+	if progress.bar != nil {
+		progress.bar.SetTotal(-1, true)
+	}
+	return c.finalizePartialFile()
+}
+
+// downloadSegment sends a Range request for the remaining bytes of specified segment and copies the response to out
+func (c *URLDownloadTask) downloadSegment(ctx context.Context, httpClient *http.Client, out *os.File, meta *partialDownloadMeta, segment *downloadSegment, rateLimiters []*util.RateLimiter, progress *segmentProgress) error {
+	start := segment.Start + atomic.LoadInt64(&segment.Downloaded)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, segment.End))
+	if validator := meta.ifRangeValidator(); validator != "" {
+		req.Header.Set("If-Range", validator)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	progress.stats.SetHTTPStatus(resp.StatusCode)
+	if resp.StatusCode != http.StatusPartialContent && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		return newHTTPStatusError(c.URL, resp)
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return errSegmentsNotResumable
+	}
+	rangeStart, _, total, err := util.ParseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil || rangeStart != start || total != meta.Length {
+		return errSegmentsNotResumable
+	}
+	return c.copySegment(out, segment, util.NewRateLimitedReader(ctx, resp.Body, rateLimiters...), progress)
+}
+
+// copySegment copies the remaining bytes of specified segment from body to the segment position of out
+func (c *URLDownloadTask) copySegment(out *os.File, segment *downloadSegment, body io.Reader, progress *segmentProgress) error {
+	buffer := make([]byte, segmentBufferSize)
+	for {
+		remaining := segment.remaining()
+		if remaining <= 0 {
+			return nil
+		}
+		if remaining < int64(len(buffer)) {
+			buffer = buffer[:remaining]
+		}
+		n, err := body.Read(buffer)
+		if n > 0 {
+			_, writeErr := out.WriteAt(buffer[:n], segment.Start+atomic.LoadInt64(&segment.Downloaded))
+			if writeErr != nil {
+				return writeErr
+			}
+			atomic.AddInt64(&segment.Downloaded, int64(n))
+			progress.add(n)
+			if diskSpaceErr := progress.guard.add(n); diskSpaceErr != nil {
+				return diskSpaceErr
+			}
+		}
+		if err == io.EOF {
+			if segment.remaining() > 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// segmentProgress reports the combined progress of all connections of a segmented download to a progress bar
+// and TaskStats, the bar speed is calculated from the interval between two reads of any connection.
+// The free disk space is checked by guard as the connections write to the partial file
+type segmentProgress struct {
+	bar        *mpb.Bar
+	stats      *TaskStats
+	guard      *diskSpaceGuard
+	lastUpdate time.Time
+	lock       *sync.Mutex
+}
+
+// add reports n downloaded bytes
+func (sp *segmentProgress) add(n int) {
+	sp.stats.AddBytes(int64(n))
+	if sp.bar == nil {
+		return
+	}
+	sp.lock.Lock()
+	now := time.Now()
+	sp.bar.EwmaIncrBy(n, now.Sub(sp.lastUpdate))
+	sp.lastUpdate = now
+	sp.lock.Unlock()
+}
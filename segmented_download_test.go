@@ -0,0 +1,120 @@
+package podownloader
+
+import (
+	"PoDownloader/util"
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newRangeRecordingServer returns a server serving content which records the Range headers of the requests
+func newRangeRecordingServer(content []byte, ranges *[]string, lock *sync.Mutex) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		lock.Lock()
+		*ranges = append(*ranges, request.Header.Get("Range"))
+		lock.Unlock()
+		writer.Header().Set("ETag", "\"v1\"")
+		http.ServeContent(writer, request, "episode.mp3", time.Time{}, bytes.NewReader(content))
+	}))
+}
+
+func newSegmentedTaskEnv(threadCount int) *TaskEnv {
+	options := DefaultDownloadOptions()
+	options.SegmentCount = 3
+	options.SegmentThreshold = 0
+	threadBudget := NewThreadBudget(threadCount)
+	// The slot of the download worker running the task
+	threadBudget.Acquire()
+	return &TaskEnv{HTTPClient: &http.Client{}, Options: options, ThreadBudget: threadBudget, Attempt: 1}
+}
+
+func TestURLDownloadTask_DownloadSegments(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 400*1024)
+	var (
+		ranges []string
+		lock   = &sync.Mutex{}
+	)
+	server := newRangeRecordingServer(content, &ranges, lock)
+	defer server.Close()
+	task := &URLDownloadTask{JobType: JobTypeEnclosure, URL: server.URL, Dest: filepath.Join(t.TempDir(), "episode.mp3")}
+	env := newSegmentedTaskEnv(3)
+	env.Stats = &TaskStats{}
+	assert.Nil(t, task.Execute(context.Background(), env))
+	assert.Equal(t, int64(len(content)), env.Stats.Bytes())
+	assert.Equal(t, http.StatusPartialContent, env.Stats.HTTPStatus())
+	downloaded, err := os.ReadFile(task.Dest)
+	assert.Nil(t, err)
+	assert.Equal(t, content, downloaded)
+	sort.Strings(ranges)
+	assert.Equal(t, []string{"", "bytes=1365333-2730665", "bytes=2730666-4095999"}, ranges)
+	// The slots of the additional connections are released
+	assert.Equal(t, 2, env.ThreadBudget.TryAcquire(2))
+
+	// No additional connections when there are no idle slots
+	ranges = nil
+	task.Dest = filepath.Join(t.TempDir(), "episode.mp3")
+	assert.Nil(t, task.Execute(context.Background(), newSegmentedTaskEnv(1)))
+	assert.Equal(t, []string{""}, ranges)
+
+	// The additional connections are limited by the free slots of the host
+	ranges = nil
+	task.Dest = filepath.Join(t.TempDir(), "episode.mp3")
+	env = newSegmentedTaskEnv(3)
+	env.HostLimiter = NewHostLimiter(2, 0)
+	host := util.GetURLHost(server.URL)
+	// The slot of the task itself
+	ok, _ := env.HostLimiter.TryAcquire(host, time.Now())
+	assert.True(t, ok)
+	assert.Nil(t, task.Execute(context.Background(), env))
+	sort.Strings(ranges)
+	assert.Equal(t, []string{"", "bytes=2048000-4095999"}, ranges)
+	// The slots of the additional connection are released, the unused thread slot is returned
+	assert.Equal(t, 1, env.HostLimiter.TryAcquireExtra(host, 2))
+	assert.Equal(t, 2, env.ThreadBudget.TryAcquire(2))
+
+	// No additional connections when the host is saturated
+	ranges = nil
+	task.Dest = filepath.Join(t.TempDir(), "episode.mp3")
+	env = newSegmentedTaskEnv(3)
+	env.HostLimiter = NewHostLimiter(1, 0)
+	ok, _ = env.HostLimiter.TryAcquire(host, time.Now())
+	assert.True(t, ok)
+	assert.Nil(t, task.Execute(context.Background(), env))
+	assert.Equal(t, []string{""}, ranges)
+}
+
+func TestURLDownloadTask_ResumeSegments(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 400*1024)
+	var (
+		ranges []string
+		lock   = &sync.Mutex{}
+	)
+	server := newRangeRecordingServer(content, &ranges, lock)
+	defer server.Close()
+	task := &URLDownloadTask{JobType: JobTypeEnclosure, URL: server.URL, Dest: filepath.Join(t.TempDir(), "episode.mp3")}
+	segments := splitSegments(int64(len(content)), 3)
+	segments[0].Downloaded = segments[0].End + 1
+	segments[1].Downloaded = 100
+	partialContent := make([]byte, len(content))
+	copy(partialContent, content[:segments[0].End+1])
+	copy(partialContent[segments[1].Start:], content[segments[1].Start:segments[1].Start+100])
+	assert.Nil(t, os.WriteFile(task.PartialFilePath(), partialContent, 0644))
+	meta := &partialDownloadMeta{URL: server.URL, ETag: "\"v1\"", Length: int64(len(content)), Segments: segments}
+	assert.Nil(t, meta.save(task.partialMetaFilePath()))
+
+	assert.Nil(t, task.Execute(context.Background(), newSegmentedTaskEnv(3)))
+	downloaded, err := os.ReadFile(task.Dest)
+	assert.Nil(t, err)
+	assert.Equal(t, content, downloaded)
+	sort.Strings(ranges)
+	assert.Equal(t, []string{"bytes=1365433-2730665", "bytes=2730666-4095999"}, ranges)
+	assert.False(t, util.IsPathExist(task.partialMetaFilePath()))
+}
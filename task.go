@@ -0,0 +1,121 @@
+package podownloader
+
+import (
+	"context"
+	"fmt"
+	"github.com/vbauerster/mpb/v8"
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// Task is a unit of work that can be queued in DownloadQueue and executed by DownloadWorker
+type Task interface {
+	// Execute executes the task, the task should stop as soon as possible when ctx is done
+	Execute(ctx context.Context, env *TaskEnv) error
+	// Destination returns the destination file path of the task
+	Destination() string
+	// Kind returns the kind of the task, such as JobTypeEnclosure
+	Kind() string
+	// DisplayName returns the name displayed in the progress bar and logs
+	DisplayName() string
+	// SizeHint returns the expected size of the destination file in bytes, 0 if unknown
+	SizeHint() int64
+}
+
+// OutputTask is implemented by tasks that write files whose checksums are recorded to the manifest
+type OutputTask interface {
+	// OutputFiles returns the paths of the files written by the task
+	OutputFiles() []string
+}
+
+// RemoteTask is implemented by tasks that fetch their content from a remote URL
+type RemoteTask interface {
+	// SourceURL returns the URL the task fetches its content from
+	SourceURL() string
+}
+
+// TaskEnv contains the shared resources and options used to execute a Task
+type TaskEnv struct {
+	HTTPClient  *http.Client
+	ProgressBar *mpb.Progress
+	Options     *DownloadOptions
+	// ThreadBudget limits the number of concurrent connections, nil if the connections are not limited.
+	// The slot held by the download worker running the task is already acquired
+	ThreadBudget *ThreadBudget
+	// HostLimiter limits the number of concurrent connections per host, nil if the hosts are not limited.
+	// The slot of the host of the task is already acquired
+	HostLimiter *HostLimiter
+	// Stats collects the statistics of the task, nil if the statistics are not collected
+	Stats *TaskStats
+	// Attempt is the current attempt number of the task, starting from 1
+	Attempt int
+}
+
+// describeTask returns the display name of specified task followed by its destination for the log messages,
+// only the destination is returned if the task has no display name
+func describeTask(task Task) string {
+	if name := task.DisplayName(); name != "" {
+		return fmt.Sprintf("%s (%s)", name, task.Destination())
+	}
+	return task.Destination()
+}
+
+// getTaskURL returns the source URL of specified task,
+// returns an empty string if the task does not implement RemoteTask
+func getTaskURL(task Task) string {
+	if remoteTask, ok := task.(RemoteTask); ok {
+		return remoteTask.SourceURL()
+	}
+	return ""
+}
+
+// TaskStats collects the statistics of a task while it is executed, it is safe for concurrent use.
+// All methods can be called on a nil *TaskStats, which collects nothing
+type TaskStats struct {
+	bytes      int64
+	httpStatus int64
+}
+
+// AddBytes adds n to the number of transferred bytes
+func (s *TaskStats) AddBytes(n int64) {
+	if s != nil {
+		atomic.AddInt64(&s.bytes, n)
+	}
+}
+
+// Bytes returns the number of transferred bytes
+func (s *TaskStats) Bytes() int64 {
+	if s == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&s.bytes)
+}
+
+// SetHTTPStatus records the status code of the last HTTP response
+func (s *TaskStats) SetHTTPStatus(statusCode int) {
+	if s != nil {
+		atomic.StoreInt64(&s.httpStatus, int64(statusCode))
+	}
+}
+
+// HTTPStatus returns the status code of the last HTTP response, 0 if no response was received
+func (s *TaskStats) HTTPStatus() int {
+	if s == nil {
+		return 0
+	}
+	return int(atomic.LoadInt64(&s.httpStatus))
+}
+
+// statsReader is an io.Reader that counts the bytes read from the underlying reader to TaskStats
+type statsReader struct {
+	reader io.Reader
+	stats  *TaskStats
+}
+
+// Read implements the io.Reader interface
+func (r *statsReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.stats.AddBytes(int64(n))
+	return n, err
+}
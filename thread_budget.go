@@ -0,0 +1,44 @@
+package podownloader
+
+// ThreadBudget limits the number of concurrent connections of all download workers.
+// A download worker holds a slot while it is running a task,
+// and segmented downloads use the idle slots for their additional connections
+type ThreadBudget struct {
+	slots chan struct{}
+}
+
+// NewThreadBudget initializes and returns a ThreadBudget instance with specified number of slots
+func NewThreadBudget(size int) *ThreadBudget {
+	if size < 1 {
+		size = 1
+	}
+	return &ThreadBudget{
+		slots: make(chan struct{}, size),
+	}
+}
+
+// Acquire blocks until a slot is acquired
+func (tb *ThreadBudget) Acquire() {
+	tb.slots <- struct{}{}
+}
+
+// TryAcquire acquires up to n slots without blocking and returns the number of acquired slots
+func (tb *ThreadBudget) TryAcquire(n int) int {
+	acquired := 0
+	for acquired < n {
+		select {
+		case tb.slots <- struct{}{}:
+			acquired++
+		default:
+			return acquired
+		}
+	}
+	return acquired
+}
+
+// Release releases n slots
+func (tb *ThreadBudget) Release(n int) {
+	for i := 0; i < n; i++ {
+		<-tb.slots
+	}
+}
@@ -0,0 +1,18 @@
+package podownloader
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestThreadBudget_TryAcquire(t *testing.T) {
+	threadBudget := NewThreadBudget(3)
+	threadBudget.Acquire()
+	assert.Equal(t, 2, threadBudget.TryAcquire(4))
+	assert.Equal(t, 0, threadBudget.TryAcquire(1))
+	threadBudget.Release(2)
+	assert.Equal(t, 1, threadBudget.TryAcquire(1))
+	threadBudget.Release(1)
+	threadBudget.Acquire()
+	assert.Equal(t, 1, threadBudget.TryAcquire(2))
+}
@@ -0,0 +1,27 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// GetFreeDiskSpace returns the number of bytes available to the current user on the filesystem containing specified path,
+// if the path does not exist, the free space of the filesystem containing its nearest existing parent directory is returned
+func GetFreeDiskSpace(path string) (int64, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		_, err = os.Stat(absPath)
+		if err == nil || !os.IsNotExist(err) {
+			break
+		}
+		parent := filepath.Dir(absPath)
+		if parent == absPath {
+			break
+		}
+		absPath = parent
+	}
+	return getFreeDiskSpace(absPath)
+}
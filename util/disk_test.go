@@ -0,0 +1,18 @@
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetFreeDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	freeSpace, err := GetFreeDiskSpace(dir)
+	assert.Nil(t, err)
+	assert.Greater(t, freeSpace, int64(0))
+	// A path that does not exist uses the filesystem of its nearest existing parent directory
+	notExistFreeSpace, err := GetFreeDiskSpace(filepath.Join(dir, "not", "exist"))
+	assert.Nil(t, err)
+	assert.Greater(t, notExistFreeSpace, int64(0))
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package util
+
+import "syscall"
+
+// getFreeDiskSpace returns the number of bytes available to the current user on the filesystem containing specified existing path
+func getFreeDiskSpace(path string) (int64, error) {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(path, &stat)
+	if err != nil {
+		return 0, err
+	}
+	return int64(uint64(stat.Bavail) * uint64(stat.Bsize)), nil
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package util
+
+import "golang.org/x/sys/windows"
+
+// getFreeDiskSpace returns the number of bytes available to the current user on the filesystem containing specified existing path
+func getFreeDiskSpace(path string) (int64, error) {
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var freeBytesAvailable, totalBytes, totalFreeBytes uint64
+	err = windows.GetDiskFreeSpaceEx(pathPtr, &freeBytesAvailable, &totalBytes, &totalFreeBytes)
+	if err != nil {
+		return 0, err
+	}
+	return int64(freeBytesAvailable), nil
+}
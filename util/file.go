@@ -1,15 +1,23 @@
 package util
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 )
 
+// TempFileSuffix is the suffix of the temporary files created before they are renamed to the destination file path
+const TempFileSuffix = ".tmp"
+
 // mimeTypeToExtensionName maps mime type name to file extension name
 // See also:
 // - https://developer.mozilla.org/en-US/docs/Web/HTTP/Basics_of_HTTP/MIME_types/Common_types
@@ -57,6 +65,20 @@ func GetExtensionNameByMimeType(mimeType string) (string, bool) {
 	return extensionName, ok
 }
 
+// GetMediaTypeFamily returns the family of specified Content-Type, for example "audio", "video", "image" or "xml".
+// An empty string will be returned when the Content-Type is empty or only describes arbitrary binary data
+// (application/octet-stream), which means the family of the content is unknown
+func GetMediaTypeFamily(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "application/octet-stream" || mediaType == "binary/octet-stream" {
+		return ""
+	}
+	if mediaType == "text/xml" || mediaType == "application/xml" || strings.HasSuffix(mediaType, "+xml") {
+		return "xml"
+	}
+	return strings.SplitN(mediaType, "/", 2)[0]
+}
+
 // GetRemoteFileExtensionName returns the extension name of specified URL
 // Try to determine the file extension name based on the string after the last dot in the URL first,
 // if can not determine the file extension name based on that, an HTTP HEAD request will be sent, then
@@ -96,6 +118,15 @@ func IsPathExist(path string) bool {
 	return true
 }
 
+// IsFileExist returns true if specified path exists and is a regular file, otherwise returns false
+func IsFileExist(path string) bool {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return stat.Mode().IsRegular()
+}
+
 // GetFileSize returns file size in bytes
 func GetFileSize(path string) (int64, error) {
 	stat, err := os.Stat(path)
@@ -105,6 +136,21 @@ func GetFileSize(path string) (int64, error) {
 	return stat.Size(), nil
 }
 
+// GetFileSHA256 returns the hex encoded SHA-256 digest and the size in bytes of specified file
+func GetFileSHA256(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
 // Mkdir creates a new directory with the specified path and permission 0755
 func Mkdir(path string) error {
 	return os.Mkdir(path, 0755)
@@ -174,6 +220,114 @@ func WriteContentToFile(content string, destFilePath string) error {
 	if err != nil {
 		return err
 	}
+	defer out.Close()
+	_, err = out.WriteString(content)
+	return err
+}
+
+// WriteContentToFileAtomically writes specified content to a temporary file in the destination directory,
+// then renames the temporary file to specified destination file path after the content is completely written,
+// so the destination file will never contain partially written content. The temporary file has a unique name,
+// so concurrent writers of the same destination do not collide, and it is removed if anything fails
+func WriteContentToFileAtomically(content string, destFilePath string) error {
+	out, err := createTempFile(destFilePath)
+	if err != nil {
+		return err
+	}
+	tempFilePath := out.Name()
 	_, err = out.WriteString(content)
+	if err == nil {
+		err = out.Sync()
+	}
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFilePath, destFilePath)
+	}
+	if err != nil {
+		_ = os.Remove(tempFilePath)
+		return err
+	}
+	return nil
+}
+
+// createTempFile creates a temporary file with a unique name in the directory of specified destination file path
+func createTempFile(destFilePath string) (*os.File, error) {
+	out, err := os.CreateTemp(filepath.Dir(destFilePath), "."+filepath.Base(destFilePath)+".*"+TempFileSuffix)
+	if err != nil {
+		return nil, err
+	}
+	// os.CreateTemp creates the file with permission 0600, the destination file is made readable like os.Create does
+	err = out.Chmod(0644)
+	if err != nil {
+		_ = out.Close()
+		_ = os.Remove(out.Name())
+		return nil, err
+	}
+	return out, nil
+}
+
+// LinkFile creates a hard link to the source file at specified destination file path,
+// an existing destination file is replaced atomically
+func LinkFile(srcFilePath string, destFilePath string) error {
+	tempFile, err := createTempFile(destFilePath)
+	if err != nil {
+		return err
+	}
+	tempFilePath := tempFile.Name()
+	_ = tempFile.Close()
+	// The unique name is reserved by the temporary file, which is replaced by the hard link
+	_ = os.Remove(tempFilePath)
+	err = os.Link(srcFilePath, tempFilePath)
+	if err == nil {
+		err = os.Rename(tempFilePath, destFilePath)
+	}
+	// Renaming does nothing if the destination is already a link to the source file, the temporary link is removed
+	// in that case as well
+	_ = os.Remove(tempFilePath)
+	return err
+}
+
+// LinkOrCopyFile creates a hard link to the source file at specified destination file path,
+// the source file is copied if the hard link can not be created, e.g. they are on different filesystems.
+// An existing destination file is replaced atomically
+func LinkOrCopyFile(srcFilePath string, destFilePath string) error {
+	if LinkFile(srcFilePath, destFilePath) == nil {
+		return nil
+	}
+	out, err := createTempFile(destFilePath)
+	if err != nil {
+		return err
+	}
+	tempFilePath := out.Name()
+	err = copyFile(srcFilePath, out)
+	if err == nil {
+		err = os.Rename(tempFilePath, destFilePath)
+	}
+	if err != nil {
+		_ = os.Remove(tempFilePath)
+		return err
+	}
+	return nil
+}
+
+// copyFile copies the source file to specified output file, flushes it to disk and closes it
+func copyFile(srcFilePath string, out *os.File) error {
+	in, err := os.Open(srcFilePath)
+	if err != nil {
+		_ = out.Close()
+		return err
+	}
+	defer in.Close()
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
 	return err
 }
@@ -1,9 +1,14 @@
 package util
 
 import (
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -18,6 +23,17 @@ func TestGetExtensionNameByMimeType(t *testing.T) {
 	assert.Equal(t, "jpg", extensionName)
 }
 
+func TestGetMediaTypeFamily(t *testing.T) {
+	assert.Equal(t, "audio", GetMediaTypeFamily("audio/mpeg"))
+	assert.Equal(t, "video", GetMediaTypeFamily("video/mp4"))
+	assert.Equal(t, "image", GetMediaTypeFamily("image/jpeg"))
+	assert.Equal(t, "xml", GetMediaTypeFamily("application/rss+xml; charset=utf-8"))
+	assert.Equal(t, "xml", GetMediaTypeFamily("text/xml"))
+	assert.Equal(t, "text", GetMediaTypeFamily("text/html; charset=utf-8"))
+	assert.Equal(t, "", GetMediaTypeFamily("application/octet-stream"))
+	assert.Equal(t, "", GetMediaTypeFamily(""))
+}
+
 func TestGetRemoteFileExtensionName(t *testing.T) {
 	jpegServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		writer.Header().Set("Content-Type", "image/jpeg")
@@ -52,3 +68,125 @@ func TestGetRemoteFileSize(t *testing.T) {
 	fileSize, err = GetRemoteFileSize(&http.Client{}, "!@#$%^&*()")
 	assert.NotNil(t, err)
 }
+
+func TestIsFileExist(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "foobar.txt")
+	assert.False(t, IsFileExist(filePath))
+	assert.Nil(t, os.WriteFile(filePath, []byte("foobar"), 0644))
+	assert.True(t, IsFileExist(filePath))
+	assert.False(t, IsFileExist(tempDir))
+}
+
+func TestWriteContentToFileAtomically(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "shownotes.html")
+	assert.Nil(t, WriteContentToFileAtomically("HelloWorld", filePath))
+	content, err := GetFileContent(filePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "HelloWorld", content)
+	assert.Nil(t, WriteContentToFileAtomically("Replaced", filePath))
+	content, err = GetFileContent(filePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "Replaced", content)
+	// No temporary file is left behind
+	entries, err := os.ReadDir(filepath.Dir(filePath))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.NotNil(t, WriteContentToFileAtomically("HelloWorld", filepath.Join(t.TempDir(), "foo", "bar.html")))
+	// A directory at the destination fails the rename, the temporary file is removed
+	dirPath := filepath.Join(t.TempDir(), "dir")
+	assert.Nil(t, os.MkdirAll(filepath.Join(dirPath, "child"), 0755))
+	assert.NotNil(t, WriteContentToFileAtomically("HelloWorld", dirPath))
+	entries, err = os.ReadDir(filepath.Dir(dirPath))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+}
+
+func TestWriteContentToFileAtomicallyConcurrent(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "shownotes.html")
+	contents := []string{strings.Repeat("a", 100000), strings.Repeat("b", 100000)}
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(content string) {
+			defer wg.Done()
+			assert.Nil(t, WriteContentToFileAtomically(content, filePath))
+		}(contents[i%2])
+	}
+	wg.Wait()
+	content, err := GetFileContent(filePath)
+	assert.Nil(t, err)
+	assert.Contains(t, contents, content)
+}
+
+func TestGetFileSHA256(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "foo.txt")
+	assert.Nil(t, os.WriteFile(filePath, []byte("foobar"), 0644))
+	digest, size, err := GetFileSHA256(filePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2", digest)
+	assert.Equal(t, int64(6), size)
+	_, _, err = GetFileSHA256(filepath.Join(t.TempDir(), "not_exist.txt"))
+	assert.NotNil(t, err)
+}
+
+func TestLinkOrCopyFile(t *testing.T) {
+	tempDir := t.TempDir()
+	srcFilePath := filepath.Join(tempDir, "src.txt")
+	destFilePath := filepath.Join(tempDir, "dest.txt")
+	assert.Nil(t, os.WriteFile(srcFilePath, []byte("foobar"), 0644))
+	assert.Nil(t, os.WriteFile(destFilePath, []byte("old content"), 0644))
+	assert.Nil(t, LinkOrCopyFile(srcFilePath, destFilePath))
+	content, err := os.ReadFile(destFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "foobar", string(content))
+	srcStat, _ := os.Stat(srcFilePath)
+	destStat, _ := os.Stat(destFilePath)
+	assert.True(t, os.SameFile(srcStat, destStat))
+	assert.NotNil(t, LinkOrCopyFile(filepath.Join(tempDir, "not_exist.txt"), destFilePath))
+	// A stray file with the old fixed temporary name is neither used nor removed, and no temporary file is left
+	assert.Nil(t, os.WriteFile(destFilePath+TempFileSuffix, []byte("stray"), 0644))
+	assert.Nil(t, LinkFile(srcFilePath, destFilePath))
+	entries, err := os.ReadDir(tempDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(entries))
+	content, err = os.ReadFile(destFilePath + TempFileSuffix)
+	assert.Nil(t, err)
+	assert.Equal(t, "stray", string(content))
+}
+
+func TestLinkFile_Concurrent(t *testing.T) {
+	tempDir := t.TempDir()
+	destFilePath := filepath.Join(tempDir, "dest.txt")
+	var srcFilePaths []string
+	for i := 0; i < 8; i++ {
+		srcFilePath := filepath.Join(tempDir, fmt.Sprintf("src%d.txt", i))
+		assert.Nil(t, os.WriteFile(srcFilePath, []byte("foobar"), 0644))
+		srcFilePaths = append(srcFilePaths, srcFilePath)
+	}
+	var doneWg sync.WaitGroup
+	for _, srcFilePath := range srcFilePaths {
+		doneWg.Add(1)
+		go func(srcFilePath string) {
+			defer doneWg.Done()
+			assert.Nil(t, LinkOrCopyFile(srcFilePath, destFilePath))
+		}(srcFilePath)
+	}
+	doneWg.Wait()
+	entries, err := os.ReadDir(tempDir)
+	assert.Nil(t, err)
+	assert.Equal(t, len(srcFilePaths)+1, len(entries))
+}
+
+func TestCopyFile(t *testing.T) {
+	tempDir := t.TempDir()
+	srcFilePath := filepath.Join(tempDir, "src.txt")
+	destFilePath := filepath.Join(tempDir, "dest.txt")
+	assert.Nil(t, os.WriteFile(srcFilePath, []byte("foobar"), 0644))
+	out, err := os.Create(destFilePath)
+	assert.Nil(t, err)
+	assert.Nil(t, copyFile(srcFilePath, out))
+	content, err := os.ReadFile(destFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "foobar", string(content))
+}
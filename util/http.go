@@ -0,0 +1,76 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseContentRange parses the value of an HTTP Content-Range header, for example "bytes 100-199/1000",
+// and returns the first byte position, the last byte position and the complete length.
+// When the complete length is unknown ("bytes 100-199/*"), total will be -1.
+// When the header describes an unsatisfied range ("bytes */1000"), start and end will be -1.
+func ParseContentRange(contentRange string) (start int64, end int64, total int64, err error) {
+	if !strings.HasPrefix(contentRange, "bytes ") {
+		return 0, 0, 0, fmt.Errorf("invalid content range: %s", contentRange)
+	}
+	rangeAndTotal := strings.SplitN(strings.TrimPrefix(contentRange, "bytes "), "/", 2)
+	if len(rangeAndTotal) != 2 {
+		return 0, 0, 0, fmt.Errorf("invalid content range: %s", contentRange)
+	}
+	total = -1
+	if rangeAndTotal[1] != "*" {
+		total, err = strconv.ParseInt(rangeAndTotal[1], 10, 64)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid content range: %s", contentRange)
+		}
+	}
+	if rangeAndTotal[0] == "*" {
+		if total < 0 {
+			return 0, 0, 0, errors.New("invalid content range: unsatisfied range without complete length")
+		}
+		return -1, -1, total, nil
+	}
+	positions := strings.SplitN(rangeAndTotal[0], "-", 2)
+	if len(positions) != 2 {
+		return 0, 0, 0, fmt.Errorf("invalid content range: %s", contentRange)
+	}
+	start, err = strconv.ParseInt(positions[0], 10, 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid content range: %s", contentRange)
+	}
+	end, err = strconv.ParseInt(positions[1], 10, 64)
+	if err != nil || end < start {
+		return 0, 0, 0, fmt.Errorf("invalid content range: %s", contentRange)
+	}
+	return start, end, total, nil
+}
+
+// IsStrongETag returns true if the specified ETag is a strong validator,
+// only strong validators can be used in an If-Range request header
+func IsStrongETag(etag string) bool {
+	return etag != "" && !strings.HasPrefix(etag, "W/")
+}
+
+// ParseRetryAfter parses the value of an HTTP Retry-After header, which is either a number of seconds
+// or an HTTP date, and returns the delay relative to now. Returns 0 if the value is empty or invalid
+func ParseRetryAfter(retryAfter string, now time.Time) time.Duration {
+	retryAfter = strings.TrimSpace(retryAfter)
+	if retryAfter == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseInt(retryAfter, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	retryTime, err := http.ParseTime(retryAfter)
+	if err != nil || retryTime.Before(now) {
+		return 0
+	}
+	return retryTime.Sub(now)
+}
@@ -0,0 +1,52 @@
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseContentRange(t *testing.T) {
+	start, end, total, err := ParseContentRange("bytes 100-199/1000")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(100), start)
+	assert.Equal(t, int64(199), end)
+	assert.Equal(t, int64(1000), total)
+
+	start, end, total, err = ParseContentRange("bytes 0-9/*")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), start)
+	assert.Equal(t, int64(9), end)
+	assert.Equal(t, int64(-1), total)
+
+	start, end, total, err = ParseContentRange("bytes */1000")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-1), start)
+	assert.Equal(t, int64(-1), end)
+	assert.Equal(t, int64(1000), total)
+
+	_, _, _, err = ParseContentRange("bytes */*")
+	assert.NotNil(t, err)
+	_, _, _, err = ParseContentRange("items 0-9/10")
+	assert.NotNil(t, err)
+	_, _, _, err = ParseContentRange("bytes 9-0/10")
+	assert.NotNil(t, err)
+	_, _, _, err = ParseContentRange("bytes a-b/10")
+	assert.NotNil(t, err)
+}
+
+func TestIsStrongETag(t *testing.T) {
+	assert.True(t, IsStrongETag("\"abc\""))
+	assert.False(t, IsStrongETag("W/\"abc\""))
+	assert.False(t, IsStrongETag(""))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, 7, 24, 1, 32, 0, 0, time.UTC)
+	assert.Equal(t, 120*time.Second, ParseRetryAfter("120", now))
+	assert.Equal(t, 30*time.Second, ParseRetryAfter("Sat, 24 Jul 2021 01:32:30 GMT", now))
+	assert.Equal(t, time.Duration(0), ParseRetryAfter("Sat, 24 Jul 2021 01:31:00 GMT", now))
+	assert.Equal(t, time.Duration(0), ParseRetryAfter("", now))
+	assert.Equal(t, time.Duration(0), ParseRetryAfter("-1", now))
+	assert.Equal(t, time.Duration(0), ParseRetryAfter("foobar", now))
+}
@@ -0,0 +1,101 @@
+package util
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket rate limiter that limits the number of bytes per second,
+// it is safe to be shared by multiple goroutines
+type RateLimiter struct {
+	bytesPerSecond float64
+	tokens         float64
+	last           time.Time
+	lock           *sync.Mutex
+}
+
+// NewRateLimiter initializes and returns a RateLimiter instance that allows specified bytes per second,
+// the bucket can hold at most one second worth of bytes
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	return &RateLimiter{
+		bytesPerSecond: float64(bytesPerSecond),
+		tokens:         float64(bytesPerSecond),
+		last:           time.Now(),
+		lock:           &sync.Mutex{},
+	}
+}
+
+// reserve consumes n bytes from the bucket and returns how long the caller has to wait
+// before the consumed bytes are allowed
+func (rl *RateLimiter) reserve(n int) time.Duration {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	now := time.Now()
+	rl.tokens += now.Sub(rl.last).Seconds() * rl.bytesPerSecond
+	if rl.tokens > rl.bytesPerSecond {
+		rl.tokens = rl.bytesPerSecond
+	}
+	rl.last = now
+	rl.tokens -= float64(n)
+	if rl.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-rl.tokens / rl.bytesPerSecond * float64(time.Second))
+}
+
+// WaitN blocks until n bytes are allowed or the context is done
+func (rl *RateLimiter) WaitN(ctx context.Context, n int) error {
+	wait := rl.reserve(n)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rateLimitedReader is an io.Reader that limits the reading speed by one or more RateLimiter
+type rateLimitedReader struct {
+	ctx          context.Context
+	reader       io.Reader
+	rateLimiters []*RateLimiter
+}
+
+// NewRateLimitedReader returns an io.Reader that reads from specified reader
+// and limits the reading speed by all specified non-nil rate limiters
+func NewRateLimitedReader(ctx context.Context, reader io.Reader, rateLimiters ...*RateLimiter) io.Reader {
+	var nonNilRateLimiters []*RateLimiter
+	for _, rateLimiter := range rateLimiters {
+		if rateLimiter != nil {
+			nonNilRateLimiters = append(nonNilRateLimiters, rateLimiter)
+		}
+	}
+	if len(nonNilRateLimiters) == 0 {
+		return reader
+	}
+	return &rateLimitedReader{
+		ctx:          ctx,
+		reader:       reader,
+		rateLimiters: nonNilRateLimiters,
+	}
+}
+
+// Read implements the io.Reader interface
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		for _, rateLimiter := range r.rateLimiters {
+			if waitErr := rateLimiter.WaitN(r.ctx, n); waitErr != nil {
+				return n, waitErr
+			}
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,36 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_WaitN(t *testing.T) {
+	rateLimiter := NewRateLimiter(1000)
+	start := time.Now()
+	// The bucket is full, so the first 1000 bytes are allowed immediately
+	assert.Nil(t, rateLimiter.WaitN(context.Background(), 1000))
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+	assert.Nil(t, rateLimiter.WaitN(context.Background(), 200))
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NotNil(t, rateLimiter.WaitN(ctx, 1000))
+}
+
+func TestNewRateLimitedReader(t *testing.T) {
+	reader := bytes.NewReader([]byte("HelloWorld"))
+	assert.Equal(t, reader, NewRateLimitedReader(context.Background(), reader, nil))
+
+	start := time.Now()
+	limitedReader := NewRateLimitedReader(context.Background(), bytes.NewReader(bytes.Repeat([]byte("0"), 1500)), NewRateLimiter(1000))
+	content, err := io.ReadAll(limitedReader)
+	assert.Nil(t, err)
+	assert.Equal(t, 1500, len(content))
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+}
@@ -0,0 +1,9 @@
+package util
+
+import "os"
+
+// IsTerminal returns whether specified file is a terminal, a file that is redirected to a regular file,
+// a pipe or a character device other than a terminal such as /dev/null is not a terminal
+func IsTerminal(file *os.File) bool {
+	return isTerminal(file.Fd())
+}
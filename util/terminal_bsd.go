@@ -0,0 +1,11 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package util
+
+import "golang.org/x/sys/unix"
+
+// isTerminal returns whether specified file descriptor is a terminal by reading its terminal attributes
+func isTerminal(fd uintptr) bool {
+	_, err := unix.IoctlGetTermios(int(fd), unix.TIOCGETA)
+	return err == nil
+}
@@ -0,0 +1,11 @@
+//go:build linux
+
+package util
+
+import "golang.org/x/sys/unix"
+
+// isTerminal returns whether specified file descriptor is a terminal by reading its terminal attributes
+func isTerminal(fd uintptr) bool {
+	_, err := unix.IoctlGetTermios(int(fd), unix.TCGETS)
+	return err == nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+
+package util
+
+// isTerminal returns false because terminals can not be detected on this platform
+func isTerminal(_ uintptr) bool {
+	return false
+}
@@ -0,0 +1,26 @@
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsTerminal(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "output.log"))
+	assert.Nil(t, err)
+	defer file.Close()
+	assert.False(t, IsTerminal(file))
+
+	reader, writer, err := os.Pipe()
+	assert.Nil(t, err)
+	defer reader.Close()
+	defer writer.Close()
+	assert.False(t, IsTerminal(writer))
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	assert.Nil(t, err)
+	defer devNull.Close()
+	assert.False(t, IsTerminal(devNull))
+}
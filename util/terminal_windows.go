@@ -0,0 +1,11 @@
+//go:build windows
+
+package util
+
+import "golang.org/x/sys/windows"
+
+// isTerminal returns whether specified file handle is a console
+func isTerminal(fd uintptr) bool {
+	var mode uint32
+	return windows.GetConsoleMode(windows.Handle(fd), &mode) == nil
+}
@@ -1,6 +1,8 @@
 package util
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -51,3 +53,47 @@ func StripInvalidXmlCharacter(xml string) string {
 		return -1
 	}, xml)
 }
+
+// byteSizeUnits maps the unit suffix of a byte size to its multiplier
+var byteSizeUnits = map[string]float64{
+	"":  1,
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+	"T": 1 << 40,
+}
+
+// ParseByteSize parses a human readable byte size like "512K", "2M" or "1.5G" and returns the size in bytes,
+// units are powers of 1024 and case-insensitive, an optional "B" or "iB" suffix is allowed
+func ParseByteSize(size string) (int64, error) {
+	text := strings.ToUpper(strings.TrimSpace(size))
+	text = strings.TrimSuffix(text, "IB")
+	text = strings.TrimSuffix(text, "B")
+	unit := ""
+	if text != "" {
+		if _, ok := byteSizeUnits[text[len(text)-1:]]; ok {
+			unit = text[len(text)-1:]
+			text = text[:len(text)-1]
+		}
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid byte size: %s", size)
+	}
+	return int64(number * byteSizeUnits[unit]), nil
+}
+
+// FormatByteSize returns a human readable byte size like "512 B", "1.5 KiB" or "2.0 GiB", units are powers of 1024
+func FormatByteSize(size int64) string {
+	if size < 1<<10 {
+		return fmt.Sprintf("%d B", size)
+	}
+	units := []string{"KiB", "MiB", "GiB", "TiB"}
+	value := float64(size) / (1 << 10)
+	unitIndex := 0
+	for value >= 1<<10 && unitIndex < len(units)-1 {
+		value /= 1 << 10
+		unitIndex++
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unitIndex])
+}
@@ -26,3 +26,36 @@ func TestRemoveDuplicateItemsInStringSlice(t *testing.T) {
 	assert.ElementsMatch(t, []string{"a", "b", "c", "d"}, result)
 	assert.ElementsMatch(t, []string{"a", "a", "d"}, duplicated)
 }
+
+func TestParseByteSize(t *testing.T) {
+	for text, expected := range map[string]int64{
+		"100":   100,
+		"512K":  512 * 1024,
+		"2M":    2 * 1024 * 1024,
+		"2m":    2 * 1024 * 1024,
+		"1.5G":  1536 * 1024 * 1024,
+		"2MB":   2 * 1024 * 1024,
+		"2MiB":  2 * 1024 * 1024,
+		" 1K ":  1024,
+		"100B":  100,
+		"0":     0,
+		"1T":    1 << 40,
+		"0.5KB": 512,
+	} {
+		size, err := ParseByteSize(text)
+		assert.Nil(t, err, text)
+		assert.Equal(t, expected, size, text)
+	}
+	for _, text := range []string{"", "M", "-1M", "foobar", "1X"} {
+		_, err := ParseByteSize(text)
+		assert.NotNil(t, err, text)
+	}
+}
+
+func TestFormatByteSize(t *testing.T) {
+	assert.Equal(t, "0 B", FormatByteSize(0))
+	assert.Equal(t, "1023 B", FormatByteSize(1023))
+	assert.Equal(t, "1.5 KiB", FormatByteSize(1536))
+	assert.Equal(t, "2.0 MiB", FormatByteSize(2*1024*1024))
+	assert.Equal(t, "3.0 TiB", FormatByteSize(3*1024*1024*1024*1024))
+}
@@ -2,6 +2,7 @@ package util
 
 import (
 	url2 "net/url"
+	"strings"
 )
 
 // IsValidHTTPLink returns true if specified url is a valid http link, otherwise it returns false
@@ -23,3 +24,13 @@ func StripQueryParam(inURL string) string {
 	u.Fragment = ""
 	return u.String()
 }
+
+// GetURLHost returns the lowercased host (including port if present) of specified URL,
+// returns an empty string if the URL can not be parsed
+func GetURLHost(inURL string) string {
+	u, err := url2.Parse(inURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
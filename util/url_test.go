@@ -13,3 +13,10 @@ func TestIsHttpLink(t *testing.T) {
 	assert.False(t, IsValidHTTPLink("ftp://ftp.example.org/"))
 	assert.False(t, IsValidHTTPLink("!@#$%^&*()_+"))
 }
+
+func TestGetURLHost(t *testing.T) {
+	assert.Equal(t, "example.org", GetURLHost("https://Example.org/rss.xml"))
+	assert.Equal(t, "localhost:8080", GetURLHost("http://localhost:8080/episode.mp3?foo=bar"))
+	assert.Equal(t, "", GetURLHost("/tmp/a.xml"))
+	assert.Equal(t, "", GetURLHost("http://[::1"))
+}